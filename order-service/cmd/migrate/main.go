@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"order-service/config"
+	"order-service/internal/database"
+	"order-service/internal/logging"
+)
+
+const usage = `Использование: migrate [флаги конфигурации] <команда>
+
+Команды:
+  up        применить все новые миграции
+  down [N]  откатить последние N миграций (по умолчанию 1)
+  status    показать состояние миграций`
+
+func main() {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	args := fs.Args()
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := logging.Setup(&cfg.Log); err != nil {
+		fatal("invalid logging configuration", err)
+	}
+
+	db, err := database.NewDatabase(&cfg.Database)
+	if err != nil {
+		fatal("database connection failed", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		n, err := db.MigrateUp()
+		if err != nil {
+			fatal("migration failed", err)
+		}
+		fmt.Printf("Применено миграций: %d\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				fmt.Fprintf(os.Stderr, "Некорректное число шагов: %s\n", args[1])
+				os.Exit(2)
+			}
+		}
+		n, err := db.MigrateDown(steps)
+		if err != nil {
+			fatal("rollback failed", err)
+		}
+		fmt.Printf("Откачено миграций: %d\n", n)
+	case "status":
+		status, err := db.MigrationStatus()
+		if err != nil {
+			fatal("reading migration status failed", err)
+		}
+		for _, s := range status {
+			applied := "не применена"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-24s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Err(err))
+	os.Exit(1)
+}
@@ -1,54 +1,260 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"order-service/config"
+	"order-service/internal/broker"
 	"order-service/internal/cache"
 	"order-service/internal/database"
+	"order-service/internal/deadletter"
+	"order-service/internal/events"
+	"order-service/internal/health"
 	"order-service/internal/http"
-	"order-service/internal/nats"
+	"order-service/internal/lifecycle"
+	"order-service/internal/logging"
+	"order-service/internal/metrics"
+	"order-service/internal/outbox"
+	"order-service/internal/reconcile"
+	"order-service/internal/reload"
+	"order-service/internal/service"
+	"order-service/internal/subscriber"
+	"order-service/internal/tracing"
 )
 
+const usage = `Использование:
+  service [флаги]               запустить сервис
+  service [флаги] config print  показать итоговую конфигурацию (секреты скрыты)`
+
 func main() {
-	log.Println("Запуск Order Service...")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	switch args := flag.Args(); {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
 
-	cfg := config.GetConfig()
+	if err := logging.Setup(&cfg.Log); err != nil {
+		fatal("invalid logging configuration", err)
+	}
+	slog.Info("starting order service")
+	slog.Debug("configuration loaded", "config", cfg)
+
+	// SIGHUP would otherwise terminate the process while it is still
+	// starting up; one arriving that early is applied once startup is
+	// done. SIGINT and SIGTERM keep their default until then, so a
+	// start that takes too long can still be interrupted.
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), &cfg.Tracing)
+	if err != nil {
+		fatal("tracing setup failed", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("tracing shutdown failed", logging.Err(err))
+		}
+	}()
 
 	db, err := database.NewDatabase(&cfg.Database)
 	if err != nil {
-		log.Fatal("Ошибка подключения к БД:", err)
+		fatal("database connection failed", err)
 	}
-	defer db.Close()
 
-	orderCache := cache.NewCache()
-	if err := orderCache.RestoreFromDB(db); err != nil {
-		log.Printf("Предупреждение при восстановлении кэша: %v", err)
+	if cfg.Database.AutoMigrate {
+		if _, err := db.MigrateUp(); err != nil {
+			fatal("database migration failed", err)
+		}
 	}
 
-	subscriber, err := nats.NewSubscriber(&cfg.NATS, orderCache, db)
+	serviceMetrics := metrics.New()
+	orderEvents := events.NewHub(&cfg.Events)
+
+	orderCache := cache.NewCache(
+		cache.WithLoader(db, cfg.Cache.NegativeTTL),
+		cache.WithObserver(orderEvents.Publish),
+		cache.WithLimits(cacheLimits(&cfg.Cache)),
+	)
+	serviceMetrics.RegisterCache(orderCache)
+
+	// Background jobs, including an asynchronous cache restore, stop when
+	// ctx is cancelled on shutdown, before the database is closed.
+	ctx, stopJobs := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	restore := func() {
+		if err := orderCache.RestoreFromDB(ctx, db, &cfg.Cache); err != nil {
+			slog.Warn("cache restore incomplete", logging.Err(err))
+		}
+	}
+	if cfg.Cache.RestoreAsync {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			restore()
+		}()
+	} else {
+		restore()
+	}
+
+	msgBroker, err := broker.New(cfg)
 	if err != nil {
-		log.Fatal("Ошибка подключения к NATS:", err)
+		fatal("broker connection failed", err)
 	}
-	defer subscriber.Close()
 
-	if err := subscriber.Subscribe(); err != nil {
-		log.Fatal("Ошибка подписки:", err)
+	orders := service.NewService(orderCache, db, service.WithMetrics(serviceMetrics))
+
+	orderSubscriber := subscriber.NewSubscriber(msgBroker, orders, db, &cfg.Broker,
+		subscriber.WithMetrics(serviceMetrics))
+	if err := orderSubscriber.Subscribe(); err != nil {
+		fatal("broker subscription failed", err)
 	}
 
-	server := http.NewServer(&cfg.HTTP, orderCache)
+	relay := outbox.NewRelay(db, msgBroker, &cfg.Outbox)
+	reconciler := reconcile.NewReconciler(orderCache, db, &cfg.Reconcile)
+	for _, run := range []func(context.Context){relay.Run, reconciler.Run, orderCache.Run} {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			run(ctx)
+		}()
+	}
+
+	deadLetters := deadletter.NewManager(db, msgBroker)
+
+	readiness := health.NewChecker(&cfg.Health)
+	readiness.Add("database", health.Database(db))
+	if conn, ok := msgBroker.(health.Connection); ok {
+		readiness.Add("broker", health.Broker(conn))
+	}
+	readiness.Add("cache", health.Cache(orderCache))
+	readiness.Add("messages", health.Messages(orderSubscriber))
+
+	server := http.NewServer(&cfg.HTTP, orderCache,
+		http.WithDeadLetters(deadLetters),
+		http.WithWarmup(orderCache),
+		http.WithCacheStats(orderCache),
+		http.WithEvents(orderEvents),
+		http.WithIngester(orders),
+		http.WithReconciler(reconciler),
+		http.WithReadiness(readiness),
+		http.WithMetrics(serviceMetrics),
+	)
 	go func() {
 		if err := server.Start(); err != nil {
-			log.Fatal("Ошибка HTTP сервера:", err)
+			fatal("HTTP server failed", err)
 		}
 	}()
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
+	reloader := reload.New(cfg, func() (*config.Config, error) {
+		fs := flag.NewFlagSet("reload", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		return config.Load(fs, os.Args[1:])
+	})
+	reloader.Subscribe(func(c *config.Config) {
+		if err := logging.Setup(&c.Log); err != nil {
+			slog.Error("logging reconfiguration failed", logging.Err(err))
+		}
+	})
+	reloader.Subscribe(func(c *config.Config) {
+		orderCache.SetLimits(cacheLimits(&c.Cache))
+		orderCache.SetNegativeTTL(c.Cache.NegativeTTL)
+	})
+	reloader.Subscribe(func(c *config.Config) {
+		server.SetRequestTimeout(c.HTTP.RequestTimeout)
+	})
+	if cfg.Reload.WatchInterval > 0 && cfg.File != "" {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			reloader.Watch(ctx, cfg.File, cfg.Reload.WatchInterval)
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	for running := true; running; {
+		select {
+		case <-reloads:
+			slog.Info("reloading configuration")
+			reloader.Reload()
+		case <-stop:
+			running = false
+		}
+	}
+
+	slog.Info("stopping order service")
+
+	// Messages are drained first, since handling them needs the
+	// database; HTTP ingestion and the background jobs need it too.
+	shutdown := lifecycle.NewManager(&cfg.Shutdown)
+	shutdown.Add("subscriber", orderSubscriber.Drain)
+	shutdown.Add("http", server.Shutdown)
+	shutdown.Add("background jobs", func(ctx context.Context) error {
+		stopJobs()
+		return wait(ctx, &jobs)
+	})
+	shutdown.Add("broker", func(context.Context) error { return msgBroker.Close() })
+	shutdown.Add("database", func(context.Context) error { return db.Close() })
+	if err := shutdown.Shutdown(context.Background()); err != nil {
+		slog.Error("shutdown incomplete", logging.Err(err))
+		return
+	}
+	slog.Info("order service stopped")
+}
+
+func cacheLimits(cfg *config.CacheConfig) cache.Limits {
+	return cache.Limits{
+		MaxEntries: cfg.MaxEntries,
+		MaxBytes:   int64(cfg.MaxBytes),
+		Policy:     cfg.Policy,
+		TTL:        cfg.TTL,
+	}
+}
+
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	log.Println("Остановка сервиса...")
+// fatal logs err and exits without running deferred cleanup, like
+// log.Fatal did.
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Err(err))
+	os.Exit(1)
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/reconcile"
+)
+
+// verify asks a running service to compare its cache with the database
+// and prints the report. It exits with 1 if drift remains and 2 if the
+// check could not run.
+func main() {
+	addr := flag.String("addr", "", "адрес сервиса (по умолчанию localhost и порт из конфигурации)")
+	repair := flag.Bool("repair", false, "исправить расхождения в кэше")
+	timeout := flag.Duration("timeout", 5*time.Minute, "таймаут проверки")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *addr == "" {
+		*addr = "http://localhost:" + cfg.HTTP.Port
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	url := fmt.Sprintf("%s/api/admin/reconcile?repair=%t", *addr, *repair)
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка запроса:", err)
+		os.Exit(2)
+	}
+	if cfg.HTTP.AdminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.HTTP.AdminToken)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка запроса:", err)
+		os.Exit(2)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		fmt.Fprintln(os.Stderr, "Проверка уже выполняется, повторите позже")
+		os.Exit(2)
+	}
+
+	var report reconcile.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		fmt.Fprintf(os.Stderr, "Некорректный ответ сервиса (%s): %v\n", resp.Status, err)
+		os.Exit(2)
+	}
+	if report.Error != "" {
+		fmt.Fprintln(os.Stderr, "Ошибка проверки:", report.Error)
+		os.Exit(2)
+	}
+
+	fmt.Printf("Заказов в кэше: %d, в БД: %d\n", report.CacheOrders, report.DatabaseOrders)
+	printUIDs("Нет в кэше", report.Missing)
+	printUIDs("Нет в БД", report.Extra)
+	printUIDs("Расходятся", report.Diverged)
+	if report.Repaired > 0 {
+		fmt.Printf("Исправлено: %d\n", report.Repaired)
+	}
+
+	if !report.Consistent() {
+		os.Exit(1)
+	}
+	fmt.Println("Кэш согласован с БД")
+}
+
+func printUIDs(title string, uids []string) {
+	if len(uids) == 0 {
+		return
+	}
+	fmt.Printf("%s (%d):\n", title, len(uids))
+	for _, uid := range uids {
+		fmt.Println("  " + uid)
+	}
+}
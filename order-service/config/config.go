@@ -1,66 +1,295 @@
 package config
 
 import (
-	"fmt"
-	"os"
+	"log/slog"
+	"strings"
+	"time"
 )
 
+// Config is layered by Load: Default, then the YAML file, then
+// environment variables, then command-line flags. The yaml tag names the
+// key in the file and env the variable; the flag is the variable in
+// lower case with dashes, e.g. -db-host. Settings tagged secret can also
+// be read from a file named by <env>_FILE or -<flag>-file, and are
+// redacted wherever the config is printed or logged. Settings tagged
+// reload can be changed without a restart.
 type Config struct {
-	Database DatabaseConfig
-	NATS     NATSConfig
-	HTTP     HTTPConfig
+	Database  DatabaseConfig  `yaml:"database"`
+	Broker    BrokerConfig    `yaml:"broker"`
+	NATS      NATSConfig      `yaml:"nats"`
+	Kafka     KafkaConfig     `yaml:"kafka"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Events    EventsConfig    `yaml:"events"`
+	Outbox    OutboxConfig    `yaml:"outbox"`
+	Reconcile ReconcileConfig `yaml:"reconcile"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Log       LogConfig       `yaml:"log"`
+	HTTP      HTTPConfig      `yaml:"http"`
+	Shutdown  ShutdownConfig  `yaml:"shutdown"`
+	Health    HealthConfig    `yaml:"health"`
+	Reload    ReloadConfig    `yaml:"reload"`
+
+	// File is the YAML file the config was read from, if any.
+	File string `yaml:"-"`
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
+	Host        string `yaml:"host" env:"DB_HOST"`
+	Port        string `yaml:"port" env:"DB_PORT"`
+	User        string `yaml:"user" env:"DB_USER"`
+	Password    string `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	DBName      string `yaml:"name" env:"DB_NAME"`
+	AutoMigrate bool   `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+
+	// SSLMode is one of disable, require, verify-ca or verify-full. The
+	// certificate and key are paths; sslcert and sslkey go together.
+	SSLMode     string `yaml:"sslmode" env:"DB_SSLMODE"`
+	SSLRootCert string `yaml:"sslrootcert" env:"DB_SSLROOTCERT"`
+	SSLCert     string `yaml:"sslcert" env:"DB_SSLCERT"`
+	SSLKey      string `yaml:"sslkey" env:"DB_SSLKEY"`
+
+	// Connection pool; zero MaxOpenConns and ConnMaxLifetime mean no limit.
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+}
+
+// BrokerConfig settings used to be NATS_*; the old variables are still
+// read when the new ones are not set.
+type BrokerConfig struct {
+	Type            string          `yaml:"type" env:"BROKER_TYPE" legacy:"NATS_TRANSPORT"`
+	MaxRedeliveries int             `yaml:"max_redeliveries" env:"BROKER_MAX_REDELIVERIES" legacy:"NATS_MAX_REDELIVERIES"`
+	Backoff         []time.Duration `yaml:"backoff" env:"BROKER_BACKOFF" legacy:"NATS_BACKOFF"`
 }
 
 type NATSConfig struct {
-	URL       string
-	ClusterID string
-	ClientID  string
-	Subject   string
+	URL       string `yaml:"url" env:"NATS_URL"`
+	ClusterID string `yaml:"cluster_id" env:"NATS_CLUSTER_ID"`
+	ClientID  string `yaml:"client_id" env:"NATS_CLIENT_ID"`
+	Subject   string `yaml:"subject" env:"NATS_SUBJECT"`
+	Stream    string `yaml:"stream" env:"NATS_STREAM"`
+
+	// QueueGroup applies to NATS Streaming only. An empty DurableName
+	// keeps each transport's historical name, so upgrading does not
+	// start a new durable subscription from the beginning.
+	QueueGroup  string        `yaml:"queue_group" env:"NATS_QUEUE_GROUP"`
+	DurableName string        `yaml:"durable_name" env:"NATS_DURABLE_NAME"`
+	AckWait     time.Duration `yaml:"ack_wait" env:"NATS_ACK_WAIT"`
+	MaxInflight int           `yaml:"max_inflight" env:"NATS_MAX_INFLIGHT"`
+}
+
+type KafkaConfig struct {
+	Brokers []string `yaml:"brokers" env:"KAFKA_BROKERS"`
+	Topic   string   `yaml:"topic" env:"KAFKA_TOPIC"`
+	GroupID string   `yaml:"group_id" env:"KAFKA_GROUP_ID"`
+}
+
+type CacheConfig struct {
+	RestoreBatchSize int           `yaml:"restore_batch_size" env:"CACHE_RESTORE_BATCH_SIZE"`
+	RestoreDays      int           `yaml:"restore_days" env:"CACHE_RESTORE_DAYS"`
+	RestoreAsync     bool          `yaml:"restore_async" env:"CACHE_RESTORE_ASYNC"`
+	NegativeTTL      time.Duration `yaml:"negative_ttl" env:"CACHE_NEGATIVE_TTL" reload:"true"`
+
+	MaxEntries int           `yaml:"max_entries" env:"CACHE_MAX_ENTRIES" reload:"true"`
+	MaxBytes   Size          `yaml:"max_bytes" env:"CACHE_MAX_BYTES" reload:"true"`
+	Policy     string        `yaml:"policy" env:"CACHE_POLICY" reload:"true"`
+	TTL        time.Duration `yaml:"ttl" env:"CACHE_TTL" reload:"true"`
+}
+
+type EventsConfig struct {
+	History      int `yaml:"history" env:"EVENTS_HISTORY"`
+	ClientBuffer int `yaml:"client_buffer" env:"EVENTS_CLIENT_BUFFER"`
+}
+
+type OutboxConfig struct {
+	Subject      string        `yaml:"subject" env:"OUTBOX_SUBJECT"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL"`
+	BatchSize    int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE"`
+	Retention    time.Duration `yaml:"retention" env:"OUTBOX_RETENTION"`
+}
+
+type ReconcileConfig struct {
+	Interval  time.Duration `yaml:"interval" env:"RECONCILE_INTERVAL"`
+	Repair    bool          `yaml:"repair" env:"RECONCILE_REPAIR"`
+	BatchSize int           `yaml:"batch_size" env:"RECONCILE_BATCH_SIZE"`
+}
+
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER"`
+	ServiceName  string  `yaml:"service_name" env:"TRACING_SERVICE_NAME"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool    `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" reload:"true"`
+	Format string `yaml:"format" env:"LOG_FORMAT" reload:"true"`
 }
 
 type HTTPConfig struct {
-	Port string
+	Port           string        `yaml:"port" env:"HTTP_PORT"`
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" env:"HTTP_IDEMPOTENCY_TTL"`
+	// IdempotencyMaxBytes bounds the keys in progress and the responses
+	// kept for replay; the oldest responses are forgotten first, and new
+	// keys get 429 once requests in progress fill it. Zero means no limit.
+	IdempotencyMaxBytes Size `yaml:"idempotency_max_bytes" env:"HTTP_IDEMPOTENCY_MAX_BYTES"`
+
+	// AdminToken must be sent as a bearer token to purge, delete or replay
+	// dead letters and to repair the cache. Empty disables those actions.
+	AdminToken string `yaml:"admin_token" env:"HTTP_ADMIN_TOKEN" secret:"true"`
+
+	// RequestTimeout answers 503 to requests still running after it,
+	// except streams and reconciliation. Zero means no limit.
+	RequestTimeout time.Duration `yaml:"request_timeout" env:"HTTP_REQUEST_TIMEOUT" reload:"true"`
+}
+
+type ShutdownConfig struct {
+	Timeout time.Duration `yaml:"timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+type HealthConfig struct {
+	Timeout time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT"`
+}
+
+// ReloadConfig controls reloading on changes to the config file, in
+// addition to SIGHUP. Zero WatchInterval disables the watch.
+type ReloadConfig struct {
+	WatchInterval time.Duration `yaml:"watch_interval" env:"CONFIG_WATCH_INTERVAL"`
 }
 
-func GetConfig() *Config {
+// Default returns the settings used when neither the file, the
+// environment nor flags say otherwise. There is deliberately no default
+// database password.
+func Default() *Config {
 	return &Config{
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "orderservice"),
-			Password: getEnv("DB_PASSWORD", "1234"),
-			DBName:   getEnv("DB_NAME", "ordersdb"),
+			Host:   "localhost",
+			Port:   "5432",
+			User:   "orderservice",
+			DBName: "ordersdb",
+
+			AutoMigrate: true,
+
+			SSLMode: "disable",
+
+			MaxIdleConns: 2,
+		},
+		Broker: BrokerConfig{
+			Type:            "stan",
+			MaxRedeliveries: 5,
+			Backoff:         []time.Duration{time.Second, 5 * time.Second, 30 * time.Second},
 		},
 		NATS: NATSConfig{
-			URL:       getEnv("NATS_URL", "nats://localhost:4222"),
-			ClusterID: getEnv("NATS_CLUSTER_ID", "test-cluster"),
-			ClientID:  getEnv("NATS_CLIENT_ID", "order-service"),
-			Subject:   getEnv("NATS_SUBJECT", "orders"),
+			URL:       "nats://localhost:4222",
+			ClusterID: "test-cluster",
+			ClientID:  "order-service",
+			Subject:   "orders",
+			Stream:    "ORDERS",
+
+			QueueGroup:  "order-service-group",
+			AckWait:     30 * time.Second,
+			MaxInflight: 25,
+		},
+		Kafka: KafkaConfig{
+			Brokers: []string{"localhost:9092"},
+			Topic:   "orders",
+			GroupID: "order-service",
+		},
+		Cache: CacheConfig{
+			RestoreBatchSize: 1000,
+			NegativeTTL:      30 * time.Second,
+
+			Policy: "lru",
+		},
+		Events: EventsConfig{
+			History:      1000,
+			ClientBuffer: 64,
+		},
+		Outbox: OutboxConfig{
+			Subject:      "order.accepted",
+			PollInterval: time.Second,
+			BatchSize:    100,
+			Retention:    24 * time.Hour,
+		},
+		Reconcile: ReconcileConfig{
+			Interval:  10 * time.Minute,
+			BatchSize: 1000,
+		},
+		Tracing: TracingConfig{
+			Exporter:     "none",
+			ServiceName:  "order-service",
+			OTLPInsecure: true,
+			SampleRatio:  1,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
 		},
 		HTTP: HTTPConfig{
-			Port: getEnv("HTTP_PORT", "8080"),
+			Port:                "8080",
+			IdempotencyTTL:      24 * time.Hour,
+			IdempotencyMaxBytes: 64 * MiB,
+		},
+		Shutdown: ShutdownConfig{
+			Timeout: 30 * time.Second,
+		},
+		Health: HealthConfig{
+			Timeout: 2 * time.Second,
 		},
 	}
 }
 
 func (c *DatabaseConfig) GetConnectionString() string {
-	return fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		c.Host, c.Port, c.User, c.Password, c.DBName,
+	return c.connectionString(c.Password)
+}
+
+// String is the connection string with the password redacted. It and
+// LogValue have value receivers so that printing either a DatabaseConfig
+// or a pointer to one is safe.
+func (c DatabaseConfig) String() string {
+	return c.connectionString(redact(c.Password))
+}
+
+func (c DatabaseConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("host", c.Host),
+		slog.String("port", c.Port),
+		slog.String("user", c.User),
+		slog.String("password", redact(c.Password)),
+		slog.String("dbname", c.DBName),
+		slog.String("sslmode", c.SSLMode),
 	)
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+// connectionString builds a libpq key/value DSN, quoting values so that
+// spaces or quotes in a password cannot change other parameters.
+func (c *DatabaseConfig) connectionString(password string) string {
+	params := []struct{ key, value string }{
+		{"host", c.Host},
+		{"port", c.Port},
+		{"user", c.User},
+		{"password", password},
+		{"dbname", c.DBName},
+		{"sslmode", c.SSLMode},
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+	}
+	var parts []string
+	for _, p := range params {
+		if p.value != "" {
+			parts = append(parts, p.key+"="+quoteDSNValue(p.value))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteDSNValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
 		return value
 	}
-	return defaultValue
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
 }
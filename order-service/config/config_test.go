@@ -0,0 +1,302 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	return Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func TestDefaultsAreValid(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Password != "" {
+		t.Error("There must be no default database password")
+	}
+}
+
+func TestLoadLayersFileEnvAndFlags(t *testing.T) {
+	path := writeFile(t, `
+database:
+  host: file-host
+  user: file-user
+  name: file-db
+nats:
+  ack_wait: 1m
+  max_inflight: 50
+cache:
+  max_bytes: 64MiB
+http:
+  port: "9000"
+`)
+	t.Setenv("DB_USER", "env-user")
+	t.Setenv("HTTP_PORT", "9001")
+
+	cfg, err := load(t, "-config", path, "-http-port", "9002", "-broker-backoff", "1s, 2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Host != "file-host" || cfg.Database.DBName != "file-db" {
+		t.Errorf("File should override defaults, got %+v", cfg.Database)
+	}
+	if cfg.Database.User != "env-user" {
+		t.Errorf("Env should override the file, got %q", cfg.Database.User)
+	}
+	if cfg.HTTP.Port != "9002" {
+		t.Errorf("Flags should override env, got %q", cfg.HTTP.Port)
+	}
+	if cfg.NATS.AckWait != time.Minute || cfg.NATS.MaxInflight != 50 {
+		t.Errorf("Unexpected NATS settings %+v", cfg.NATS)
+	}
+	if cfg.Cache.MaxBytes != 64*MiB {
+		t.Errorf("Expected 64MiB, got %d", cfg.Cache.MaxBytes)
+	}
+	if len(cfg.Broker.Backoff) != 2 || cfg.Broker.Backoff[1] != 2*time.Second {
+		t.Errorf("Unexpected backoff %v", cfg.Broker.Backoff)
+	}
+	if cfg.Outbox.BatchSize != 100 {
+		t.Errorf("Unset settings should keep their defaults, got %d", cfg.Outbox.BatchSize)
+	}
+}
+
+func TestLoadFileFromEnv(t *testing.T) {
+	t.Setenv(EnvFile, writeFile(t, "log:\n  level: debug\n"))
+	cfg, err := load(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Log.Level != "debug" {
+		t.Errorf("Expected level from %s, got %q", EnvFile, cfg.Log.Level)
+	}
+}
+
+func TestLoadRejectsUnknownFileKeys(t *testing.T) {
+	path := writeFile(t, "database:\n  hots: typo\n")
+	if _, err := load(t, "-config", path); err == nil || !strings.Contains(err.Error(), "hots") {
+		t.Errorf("Expected an error naming the unknown key, got %v", err)
+	}
+}
+
+func TestLoadReportsBadValues(t *testing.T) {
+	t.Setenv("CACHE_TTL", "soon")
+	_, err := load(t, "-events-history", "many")
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{"CACHE_TTL", "-events-history"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestValidateListsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.Broker.Type = "kafka"
+	cfg.Kafka.Brokers = nil
+	cfg.Cache.Policy = "fifo"
+	cfg.HTTP.Port = "80000"
+	cfg.Shutdown.Timeout = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"kafka.brokers", "cache.policy", "http.port", "shutdown.timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for input, want := range map[string]Size{
+		"0":       0,
+		"1048576": MiB,
+		"512KiB":  512 * KiB,
+		"64MB":    64_000_000,
+		"2gib":    2 * GiB,
+		"10 B":    10,
+	} {
+		got, err := ParseSize(input)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "-1", "1TB", "MiB"} {
+		if _, err := ParseSize(input); err == nil {
+			t.Errorf("ParseSize(%q) should fail", input)
+		}
+	}
+	if s := (64 * MiB).String(); s != "64MiB" {
+		t.Errorf("Expected 64MiB, got %s", s)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "hunter2"
+
+	var buf bytes.Buffer
+	if err := Print(&buf, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "hunter2") || !strings.Contains(buf.String(), redacted) {
+		t.Errorf("Password should be redacted:\n%s", buf.String())
+	}
+	if cfg.Database.Password != "hunter2" {
+		t.Error("Print must not modify the config")
+	}
+
+	// The output is a valid config file.
+	if _, err := load(t, "-config", writeFile(t, buf.String())); err != nil {
+		t.Errorf("Printed config should load back: %v", err)
+	}
+}
+
+func TestConnectionString(t *testing.T) {
+	cfg := Default().Database
+	cfg.Password = `it's a \secret`
+	cfg.SSLMode = "verify-full"
+	cfg.SSLRootCert = "/etc/ssl/ca.pem"
+
+	want := `host=localhost port=5432 user=orderservice password='it\'s a \\secret' dbname=ordersdb sslmode=verify-full sslrootcert=/etc/ssl/ca.pem`
+	if got := cfg.GetConnectionString(); got != want {
+		t.Errorf("Unexpected DSN\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestConfigNeverPrintsPassword(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "hunter2"
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("loaded", "config", cfg, "database", cfg.Database)
+	for name, out := range map[string]string{
+		"String":          cfg.String(),
+		"database String": cfg.Database.String(),
+		"Sprintf":         fmt.Sprintf("%v %+v", cfg, &cfg.Database),
+		"log":             buf.String(),
+	} {
+		if strings.Contains(out, "hunter2") {
+			t.Errorf("%s leaks the password: %s", name, out)
+		}
+		if !strings.Contains(out, redacted) {
+			t.Errorf("%s should show the password is set: %s", name, out)
+		}
+	}
+	if !strings.Contains(buf.String(), `"database":{"host":"localhost"`) {
+		t.Errorf("Expected grouped settings in the log, got %s", buf.String())
+	}
+}
+
+func TestSecretFromFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "db_password")
+	if err := os.WriteFile(secret, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DB_PASSWORD_FILE", secret)
+	cfg, err := load(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Password != "from-file" {
+		t.Errorf("Expected password from DB_PASSWORD_FILE without the newline, got %q", cfg.Database.Password)
+	}
+
+	t.Setenv("DB_PASSWORD", "from-env")
+	if _, err := load(t); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD_FILE") {
+		t.Errorf("Setting both DB_PASSWORD and DB_PASSWORD_FILE should fail, got %v", err)
+	}
+
+	t.Setenv("DB_PASSWORD", "")
+	t.Setenv("DB_PASSWORD_FILE", "")
+	cfg, err = load(t, "-db-password-file", secret)
+	if err != nil || cfg.Database.Password != "from-file" {
+		t.Errorf("Expected password from -db-password-file, got %q, %v", cfg.Database.Password, err)
+	}
+
+	t.Setenv("DB_HOST_FILE", secret)
+	cfg, err = load(t)
+	if err != nil || cfg.Database.Host != "localhost" {
+		t.Errorf("Only secrets are read from files, got host %q, %v", cfg.Database.Host, err)
+	}
+}
+
+func TestValidateSSL(t *testing.T) {
+	cfg := Default()
+	cfg.Database.SSLCert = "/etc/ssl/client.pem"
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "sslkey") || !strings.Contains(err.Error(), "sslmode") {
+		t.Errorf("Expected sslkey and sslmode errors, got %v", err)
+	}
+
+	cfg.Database.SSLMode = "prefer"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `"prefer"`) {
+		t.Errorf("Expected an unsupported sslmode error, got %v", err)
+	}
+}
+
+func TestChangesSplitsReloadableSettings(t *testing.T) {
+	current := Default()
+	next := Default()
+	next.Cache.MaxBytes = 64 * MiB
+	next.Log.Format = "text"
+	next.Database.Host = "elsewhere"
+
+	reloadable, restart := current.Changes(next)
+	if strings.Join(reloadable, ",") != "cache.max_bytes,log.format" {
+		t.Errorf("Unexpected reloadable changes %v", reloadable)
+	}
+	if strings.Join(restart, ",") != "database.host" {
+		t.Errorf("Unexpected restart-only changes %v", restart)
+	}
+
+	applied := current.WithReloadable(next)
+	if applied.Cache.MaxBytes != 64*MiB || applied.Log.Format != "text" || applied.Database.Host != "localhost" {
+		t.Errorf("Only reloadable settings should be taken, got %s", applied)
+	}
+	if current.Log.Format != Default().Log.Format {
+		t.Error("WithReloadable must not modify the config")
+	}
+}
+
+func TestLoadReadsLegacyBrokerVariables(t *testing.T) {
+	t.Setenv("NATS_TRANSPORT", "jetstream")
+	t.Setenv("NATS_MAX_REDELIVERIES", "7")
+	t.Setenv("BROKER_MAX_REDELIVERIES", "9")
+
+	cfg, err := load(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Broker.Type != "jetstream" {
+		t.Errorf("Expected the type from NATS_TRANSPORT, got %q", cfg.Broker.Type)
+	}
+	if cfg.Broker.MaxRedeliveries != 9 {
+		t.Errorf("BROKER_MAX_REDELIVERIES should win over the old name, got %d", cfg.Broker.MaxRedeliveries)
+	}
+}
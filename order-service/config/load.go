@@ -0,0 +1,254 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// EnvFile names the variable that points at the YAML file when the
+// -config flag is not given.
+const EnvFile = "CONFIG_FILE"
+
+// Load registers a flag for every setting on fs, parses args and layers
+// defaults, the YAML file, the environment and the flags, in that order.
+// The result is validated. Positional arguments are left in fs.Args().
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+	settings := settingsOf(cfg)
+
+	file := fs.String("config", "", "YAML configuration file (or "+EnvFile+")")
+	flags := make(map[*setting]string)
+	flagFiles := make(map[*setting]string)
+	for _, s := range settings {
+		fs.Func(s.flag(), s.path+" (env "+s.env+")", func(value string) error {
+			flags[s] = value
+			return nil
+		})
+		if s.secret {
+			fs.Func(s.flag()+"-file", "file holding "+s.path+" (env "+s.env+"_FILE)", func(path string) error {
+				flagFiles[s] = path
+				return nil
+			})
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	path := *file
+	if path == "" {
+		path = os.Getenv(EnvFile)
+	}
+	if path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, err
+		}
+		cfg.File = path
+	}
+
+	var errs []error
+	for _, s := range settings {
+		var path string
+		if s.secret {
+			path = os.Getenv(s.env + "_FILE")
+		}
+		value, name := os.Getenv(s.env), s.env
+		if value == "" && s.legacy != "" {
+			if value = os.Getenv(s.legacy); value != "" {
+				name = s.legacy
+				slog.Warn("deprecated environment variable, use the new name", "variable", s.legacy, "replacement", s.env)
+			}
+		}
+		value, name, err := lookup(value, name, path, s.env+"_FILE")
+		if err == nil && value != "" {
+			err = s.set(value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	for _, s := range settings {
+		value, set := flags[s]
+		path, fromFile := flagFiles[s]
+		if !set && !fromFile {
+			continue
+		}
+		value, name, err := lookup(value, "-"+s.flag(), path, "-"+s.flag()+"-file")
+		if err == nil {
+			err = s.set(value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile overlays the file onto cfg. Keys that match no setting are
+// errors, so a typo does not silently leave the default in place.
+func loadFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// lookup returns value, or the content of the file at path when that is
+// given instead, along with the name of the source for error messages.
+// Giving both is an error, since it is unclear which one is meant.
+func lookup(value, name, path, fileName string) (string, string, error) {
+	if path == "" {
+		return value, name, nil
+	}
+	if value != "" {
+		return "", name, fmt.Errorf("cannot be combined with %s", fileName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fileName, err
+	}
+	// Secret files usually end with a newline that is not part of the
+	// secret.
+	return strings.TrimRight(string(data), "\r\n"), fileName, nil
+}
+
+// setting is one leaf of Config, addressable by its path in the file,
+// its environment variable and its flag. legacy is an earlier name of
+// the variable, read when env is not set.
+type setting struct {
+	path     string
+	env      string
+	legacy   string
+	secret   bool
+	reloaded bool
+	value    reflect.Value
+}
+
+func (s *setting) flag() string {
+	return strings.ToLower(strings.ReplaceAll(s.env, "_", "-"))
+}
+
+func settingsOf(cfg *Config) []*setting {
+	var settings []*setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			path := prefix + field.Tag.Get("yaml")
+			if env, ok := field.Tag.Lookup("env"); ok {
+				settings = append(settings, &setting{
+					path:     path,
+					env:      env,
+					legacy:   field.Tag.Get("legacy"),
+					secret:   field.Tag.Get("secret") == "true",
+					reloaded: field.Tag.Get("reload") == "true",
+					value:    v.Field(i),
+				})
+				continue
+			}
+			if field.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), path+".")
+			}
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return settings
+}
+
+var (
+	durationType  = reflect.TypeOf(time.Duration(0))
+	durationsType = reflect.TypeOf([]time.Duration(nil))
+	stringsType   = reflect.TypeOf([]string(nil))
+)
+
+// set parses value the way the environment has always been read: lists
+// are comma-separated and durations use time.ParseDuration.
+func (s *setting) set(value string) error {
+	v := s.value
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch v.Type() {
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case durationsType:
+		var durations []time.Duration
+		for _, part := range strings.Split(value, ",") {
+			d, err := time.ParseDuration(strings.TrimSpace(part))
+			if err != nil {
+				return err
+			}
+			durations = append(durations, d)
+		}
+		v.Set(reflect.ValueOf(durations))
+		return nil
+	case stringsType:
+		var list []string
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, part)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const redacted = "[REDACTED]"
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// Redacted returns a copy of c with every secret that is set replaced by
+// a placeholder, so it can be shown or logged.
+func (c *Config) Redacted() *Config {
+	clone := *c
+	for _, s := range settingsOf(&clone) {
+		if s.secret {
+			s.value.SetString(redact(s.value.String()))
+		}
+	}
+	return &clone
+}
+
+// Print writes the effective configuration as YAML in the format Load
+// reads, with secrets redacted.
+func Print(w io.Writer, c *Config) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// String lists every setting as path=value on one line, secrets redacted.
+func (c Config) String() string {
+	var parts []string
+	for _, s := range settingsOf(c.Redacted()) {
+		parts = append(parts, s.path+"="+formatValue(s.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+// LogValue groups the settings by section, secrets redacted.
+func (c Config) LogValue() slog.Value {
+	var sections []slog.Attr
+	var section string
+	var attrs []slog.Attr
+	flush := func() {
+		if len(attrs) > 0 {
+			sections = append(sections, slog.Attr{Key: section, Value: slog.GroupValue(attrs...)})
+		}
+	}
+	for _, s := range settingsOf(c.Redacted()) {
+		name, key, _ := strings.Cut(s.path, ".")
+		if name != section {
+			flush()
+			section, attrs = name, nil
+		}
+		attrs = append(attrs, slog.String(key, formatValue(s.value)))
+	}
+	flush()
+	return slog.GroupValue(sections...)
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
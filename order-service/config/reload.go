@@ -0,0 +1,34 @@
+package config
+
+import "reflect"
+
+// Changes returns the paths of the settings that differ between c and
+// next, split into those that can be applied at runtime and those that
+// need a restart.
+func (c *Config) Changes(next *Config) (reloadable, restart []string) {
+	current, updated := settingsOf(c), settingsOf(next)
+	for i, s := range current {
+		if reflect.DeepEqual(s.value.Interface(), updated[i].value.Interface()) {
+			continue
+		}
+		if s.reloaded {
+			reloadable = append(reloadable, s.path)
+		} else {
+			restart = append(restart, s.path)
+		}
+	}
+	return reloadable, restart
+}
+
+// WithReloadable returns a copy of c with the settings that can change
+// at runtime taken from next. Everything else keeps its current value.
+func (c *Config) WithReloadable(next *Config) *Config {
+	clone := *c
+	updated := settingsOf(next)
+	for i, s := range settingsOf(&clone) {
+		if s.reloaded {
+			s.value.Set(updated[i].value)
+		}
+	}
+	return &clone
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Size is a number of bytes written either plainly or with a unit:
+// 512, 64KB, 64KiB, 10MB, 10MiB, 1GB, 1GiB. KB, MB and GB are powers of
+// 1000, the -iB units powers of 1024.
+type Size int64
+
+const (
+	KiB Size = 1 << 10
+	MiB Size = 1 << 20
+	GiB Size = 1 << 30
+)
+
+type sizeUnit struct {
+	suffix string
+	scale  Size
+}
+
+var sizeUnits = []sizeUnit{
+	// Longer suffixes first, so "KiB" is not read as "B".
+	{"KiB", KiB}, {"MiB", MiB}, {"GiB", GiB},
+	{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+func ParseSize(s string) (Size, error) {
+	s = strings.TrimSpace(s)
+	scale := Size(1)
+	number := s
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(s), strings.ToUpper(unit.suffix)) {
+			scale = unit.scale
+			number = strings.TrimSpace(s[:len(s)-len(unit.suffix)])
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 1048576, 512KiB or 64MB)", s)
+	}
+	if n > int64(^uint64(0)>>1)/int64(scale) {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return Size(n) * scale, nil
+}
+
+func (s *Size) UnmarshalText(text []byte) error {
+	size, err := ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = size
+	return nil
+}
+
+// MarshalText uses the largest binary unit that divides s exactly.
+func (s Size) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s Size) String() string {
+	for _, unit := range []sizeUnit{{"GiB", GiB}, {"MiB", MiB}, {"KiB", KiB}} {
+		if s != 0 && s%unit.scale == 0 {
+			return strconv.FormatInt(int64(s/unit.scale), 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(int64(s), 10)
+}
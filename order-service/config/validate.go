@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Validate reports every invalid setting at once, each prefixed with its
+// path in the config file.
+func (c *Config) Validate() error {
+	var v validator
+
+	v.port("database.port", c.Database.Port)
+	v.required("database.host", c.Database.Host)
+	v.required("database.user", c.Database.User)
+	v.required("database.name", c.Database.DBName)
+	v.oneOf("database.sslmode", c.Database.SSLMode, "disable", "require", "verify-ca", "verify-full")
+	if (c.Database.SSLCert == "") != (c.Database.SSLKey == "") {
+		v.add("database.sslcert", "sslcert and sslkey must be set together")
+	}
+	if c.Database.SSLMode == "disable" && c.Database.SSLRootCert+c.Database.SSLCert+c.Database.SSLKey != "" {
+		v.add("database.sslmode", "is disable, but certificates are configured")
+	}
+	v.nonNegative("database.max_open_conns", int64(c.Database.MaxOpenConns))
+	v.nonNegative("database.max_idle_conns", int64(c.Database.MaxIdleConns))
+	v.nonNegative("database.conn_max_lifetime", int64(c.Database.ConnMaxLifetime))
+
+	v.oneOf("broker.type", c.Broker.Type, "stan", "jetstream", "kafka")
+	v.nonNegative("broker.max_redeliveries", int64(c.Broker.MaxRedeliveries))
+	for _, d := range c.Broker.Backoff {
+		v.nonNegative("broker.backoff", int64(d))
+	}
+
+	switch c.Broker.Type {
+	case "stan", "jetstream":
+		v.required("nats.url", c.NATS.URL)
+		v.required("nats.subject", c.NATS.Subject)
+		v.positive("nats.ack_wait", int64(c.NATS.AckWait))
+		v.positive("nats.max_inflight", int64(c.NATS.MaxInflight))
+		if c.Broker.Type == "stan" {
+			v.required("nats.cluster_id", c.NATS.ClusterID)
+			v.required("nats.client_id", c.NATS.ClientID)
+			v.required("nats.queue_group", c.NATS.QueueGroup)
+		} else {
+			v.required("nats.stream", c.NATS.Stream)
+		}
+	case "kafka":
+		if len(c.Kafka.Brokers) == 0 {
+			v.add("kafka.brokers", "at least one broker is required")
+		}
+		v.required("kafka.topic", c.Kafka.Topic)
+		v.required("kafka.group_id", c.Kafka.GroupID)
+	}
+
+	v.positive("cache.restore_batch_size", int64(c.Cache.RestoreBatchSize))
+	v.nonNegative("cache.restore_days", int64(c.Cache.RestoreDays))
+	v.nonNegative("cache.negative_ttl", int64(c.Cache.NegativeTTL))
+	v.nonNegative("cache.max_entries", int64(c.Cache.MaxEntries))
+	v.nonNegative("cache.max_bytes", int64(c.Cache.MaxBytes))
+	v.oneOf("cache.policy", c.Cache.Policy, "lru", "lfu")
+	v.nonNegative("cache.ttl", int64(c.Cache.TTL))
+
+	v.nonNegative("events.history", int64(c.Events.History))
+	v.positive("events.client_buffer", int64(c.Events.ClientBuffer))
+
+	v.required("outbox.subject", c.Outbox.Subject)
+	v.positive("outbox.poll_interval", int64(c.Outbox.PollInterval))
+	v.positive("outbox.batch_size", int64(c.Outbox.BatchSize))
+	v.nonNegative("outbox.retention", int64(c.Outbox.Retention))
+
+	v.nonNegative("reconcile.interval", int64(c.Reconcile.Interval))
+	v.positive("reconcile.batch_size", int64(c.Reconcile.BatchSize))
+
+	v.oneOf("tracing.exporter", c.Tracing.Exporter, "none", "stdout", "otlp")
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		v.add("tracing.sample_ratio", "must be between 0 and 1")
+	}
+
+	v.oneOf("log.level", strings.ToLower(c.Log.Level), "debug", "info", "warn", "error")
+	v.oneOf("log.format", strings.ToLower(c.Log.Format), "json", "text")
+
+	v.port("http.port", c.HTTP.Port)
+	v.nonNegative("http.idempotency_ttl", int64(c.HTTP.IdempotencyTTL))
+	v.nonNegative("http.idempotency_max_bytes", int64(c.HTTP.IdempotencyMaxBytes))
+	v.nonNegative("http.request_timeout", int64(c.HTTP.RequestTimeout))
+
+	v.positive("shutdown.timeout", int64(c.Shutdown.Timeout))
+	v.positive("health.timeout", int64(c.Health.Timeout))
+	v.nonNegative("reload.watch_interval", int64(c.Reload.WatchInterval))
+
+	return v.err()
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(path, msg string) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", path, msg))
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(v.errs...))
+}
+
+func (v *validator) required(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(path, "is required")
+	}
+}
+
+func (v *validator) oneOf(path, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		v.add(path, fmt.Sprintf("%q is not one of %s", value, strings.Join(allowed, ", ")))
+	}
+}
+
+func (v *validator) port(path, value string) {
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+		v.add(path, fmt.Sprintf("%q is not a port number", value))
+	}
+}
+
+func (v *validator) positive(path string, n int64) {
+	if n <= 0 {
+		v.add(path, "must be greater than zero")
+	}
+}
+
+func (v *validator) nonNegative(path string, n int64) {
+	if n < 0 {
+		v.add(path, "must not be negative")
+	}
+}
@@ -0,0 +1,64 @@
+package broker
+
+import (
+	"fmt"
+	"time"
+
+	"order-service/config"
+)
+
+type Metadata struct {
+	Subject      string
+	Sequence     uint64
+	Redeliveries int
+	Timestamp    time.Time
+	Headers      map[string]string
+}
+
+type Message interface {
+	Data() []byte
+	Metadata() Metadata
+	Ack() error
+	// Nack asks for redelivery after delay. Transports without explicit
+	// negative acknowledgement redeliver once their ack timeout expires.
+	Nack(delay time.Duration) error
+}
+
+type Handler func(msg Message)
+
+type Broker interface {
+	Subscribe(handler Handler) error
+	// Unsubscribe stops delivering new messages to the handler. Calls
+	// already in progress are not waited for; messages they leave
+	// unacked are redelivered later, as after a crash.
+	Unsubscribe() error
+	Publish(subject string, data []byte) error
+	Close() error
+}
+
+// HeaderPublisher is implemented by transports that carry message
+// headers. NATS Streaming does not.
+type HeaderPublisher interface {
+	PublishWithHeaders(subject string, data []byte, headers map[string]string) error
+}
+
+func New(cfg *config.Config) (Broker, error) {
+	var (
+		b   Broker
+		err error
+	)
+	switch cfg.Broker.Type {
+	case "", "stan":
+		b, err = NewStan(&cfg.NATS)
+	case "jetstream":
+		b, err = NewJetStream(&cfg.NATS, &cfg.Broker, cfg.Outbox.Subject)
+	case "kafka":
+		b, err = NewKafka(&cfg.Kafka, &cfg.Broker)
+	default:
+		return nil, fmt.Errorf("unknown broker type %q (expected stan, jetstream or kafka)", cfg.Broker.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,222 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"order-service/config"
+	"order-service/internal/logging"
+)
+
+const (
+	// jetStreamDurable is used when NATS_DURABLE_NAME is not set.
+	jetStreamDurable = "order-service"
+	jetStreamTimeout = 10 * time.Second
+)
+
+type JetStream struct {
+	nc       *nats.Conn
+	js       jetstream.JetStream
+	consumer jetstream.ConsumeContext
+	subject  string
+	stream   string
+	durable  string
+	subjects []string
+
+	ackWait     time.Duration
+	maxInflight int
+	backoff     []time.Duration
+}
+
+// NewJetStream connects and creates or updates the stream. Besides the
+// subject orders arrive on, the stream captures the extra subjects the
+// service publishes to, such as the outbox subject: JetStream rejects a
+// publish that no stream captures.
+func NewJetStream(cfg *config.NATSConfig, brokerCfg *config.BrokerConfig, subjects ...string) (*JetStream, error) {
+	nc, err := nats.Connect(
+		cfg.URL,
+		nats.Name(cfg.ClientID),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			// Close disconnects without an error; only a lost
+			// connection is worth a warning.
+			if err != nil {
+				slog.Warn("connection lost to NATS", logging.Err(err))
+			}
+		}),
+		nats.ReconnectHandler(func(_ *nats.Conn) {
+			slog.Info("reconnected to NATS")
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	slog.Info("connected to NATS JetStream")
+
+	durable := cfg.DurableName
+	if durable == "" {
+		durable = jetStreamDurable
+	}
+	s := &JetStream{
+		nc:       nc,
+		js:       js,
+		subject:  cfg.Subject,
+		stream:   cfg.Stream,
+		durable:  durable,
+		subjects: streamSubjects(cfg.Subject, subjects),
+
+		ackWait:     cfg.AckWait,
+		maxInflight: cfg.MaxInflight,
+		backoff:     brokerCfg.Backoff,
+	}
+	if err := s.createStream(); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// streamSubjects returns the inbound subject followed by the extra ones,
+// without blanks or duplicates, which the server rejects.
+func streamSubjects(subject string, extra []string) []string {
+	subjects := []string{subject}
+	for _, s := range extra {
+		if s != "" && !slices.Contains(subjects, s) {
+			subjects = append(subjects, s)
+		}
+	}
+	return subjects
+}
+
+func (s *JetStream) createStream() error {
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+
+	_, err := s.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     s.stream,
+		Subjects: s.subjects,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating stream %s: %w", s.stream, err)
+	}
+	return nil
+}
+
+func (s *JetStream) Subscribe(handler Handler) error {
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+
+	consumer, err := s.js.CreateOrUpdateConsumer(ctx, s.stream, jetstream.ConsumerConfig{
+		Durable:       s.durable,
+		FilterSubject: s.subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       s.ackWait,
+		// The subscriber counts redeliveries and moves the message to dead
+		// letters itself. If the server gave up first, a message whose dead
+		// letter failed to save would be dropped without a record.
+		MaxDeliver:    -1,
+		MaxAckPending: s.maxInflight,
+		BackOff:       s.backoff,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating consumer %s: %w", s.durable, err)
+	}
+
+	s.consumer, err = consumer.Consume(func(msg jetstream.Msg) {
+		meta, err := msg.Metadata()
+		if err != nil {
+			slog.Error("reading message metadata failed", logging.KeySubject, msg.Subject(), logging.Err(err))
+			msg.Term()
+			return
+		}
+		handler(&jetStreamMessage{msg: msg, meta: meta})
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("subscribed to stream", "stream", s.stream, logging.KeySubject, s.subject, "durable", s.durable)
+	return nil
+}
+
+func (s *JetStream) Publish(subject string, data []byte) error {
+	return s.PublishWithHeaders(subject, data, nil)
+}
+
+func (s *JetStream) PublishWithHeaders(subject string, data []byte, headers map[string]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	for key, value := range headers {
+		msg.Header.Set(key, value)
+	}
+	_, err := s.js.PublishMsg(ctx, msg)
+	return err
+}
+
+func (s *JetStream) Connected() bool {
+	return s.nc.IsConnected()
+}
+
+// Unsubscribe stops pulling from the durable consumer. Messages already
+// fetched but not handled are redelivered once AckWait expires.
+func (s *JetStream) Unsubscribe() error {
+	if s.consumer != nil {
+		s.consumer.Stop()
+		s.consumer = nil
+	}
+	return nil
+}
+
+func (s *JetStream) Close() error {
+	s.Unsubscribe()
+	if s.nc != nil {
+		s.nc.Close()
+		slog.Info("disconnected from NATS JetStream")
+	}
+	return nil
+}
+
+type jetStreamMessage struct {
+	msg  jetstream.Msg
+	meta *jetstream.MsgMetadata
+}
+
+func (m *jetStreamMessage) Data() []byte {
+	return m.msg.Data()
+}
+
+func (m *jetStreamMessage) Metadata() Metadata {
+	headers := make(map[string]string, len(m.msg.Headers()))
+	for key := range m.msg.Headers() {
+		headers[key] = m.msg.Headers().Get(key)
+	}
+	return Metadata{
+		Subject:      m.msg.Subject(),
+		Sequence:     m.meta.Sequence.Stream,
+		Redeliveries: int(m.meta.NumDelivered) - 1,
+		Timestamp:    m.meta.Timestamp,
+		Headers:      headers,
+	}
+}
+
+func (m *jetStreamMessage) Ack() error {
+	return m.msg.Ack()
+}
+
+func (m *jetStreamMessage) Nack(delay time.Duration) error {
+	return m.msg.NakWithDelay(delay)
+}
@@ -0,0 +1,165 @@
+package broker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"order-service/config"
+)
+
+func runJetStreamServer(t *testing.T) *server.Server {
+	t.Helper()
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal("Error creating NATS server:", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func newTestJetStream(t *testing.T) *JetStream {
+	t.Helper()
+	srv := runJetStreamServer(t)
+	cfg := &config.NATSConfig{
+		URL:      srv.ClientURL(),
+		ClientID: "order-service-test",
+		Subject:  "orders",
+		Stream:   "ORDERS",
+	}
+	brokerCfg := &config.BrokerConfig{
+		MaxRedeliveries: 2,
+		Backoff:         []time.Duration{10 * time.Millisecond},
+	}
+
+	js, err := NewJetStream(cfg, brokerCfg)
+	if err != nil {
+		t.Fatal("Error connecting to JetStream:", err)
+	}
+	t.Cleanup(func() { js.Close() })
+	return js
+}
+
+type recorder struct {
+	mu    sync.Mutex
+	metas []Metadata
+}
+
+func (r *recorder) add(meta Metadata) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metas = append(r.metas, meta)
+}
+
+func (r *recorder) all() []Metadata {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Metadata(nil), r.metas...)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func TestJetStreamDeliversAndAcks(t *testing.T) {
+	js := newTestJetStream(t)
+	rec := &recorder{}
+
+	err := js.Subscribe(func(msg Message) {
+		rec.add(msg.Metadata())
+		msg.Ack()
+	})
+	if err != nil {
+		t.Fatal("Error subscribing:", err)
+	}
+
+	if err := js.Publish("orders", []byte(`{"order_uid":"1"}`)); err != nil {
+		t.Fatal("Error publishing:", err)
+	}
+
+	waitFor(t, "delivery", func() bool { return len(rec.all()) == 1 })
+	meta := rec.all()[0]
+	if meta.Subject != "orders" || meta.Sequence != 1 || meta.Redeliveries != 0 {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+}
+
+// The server must not stop redelivering after MaxRedeliveries: the
+// subscriber decides when to dead-letter, and nacks if that fails.
+func TestJetStreamRedeliversNackedUntilAcked(t *testing.T) {
+	js := newTestJetStream(t)
+	rec := &recorder{}
+
+	err := js.Subscribe(func(msg Message) {
+		meta := msg.Metadata()
+		rec.add(meta)
+		if meta.Redeliveries < 4 {
+			msg.Nack(10 * time.Millisecond)
+			return
+		}
+		msg.Ack()
+	})
+	if err != nil {
+		t.Fatal("Error subscribing:", err)
+	}
+
+	if err := js.Publish("orders", []byte("{}")); err != nil {
+		t.Fatal("Error publishing:", err)
+	}
+
+	waitFor(t, "redeliveries", func() bool { return len(rec.all()) == 5 })
+	time.Sleep(100 * time.Millisecond)
+
+	metas := rec.all()
+	if len(metas) != 5 {
+		t.Fatalf("Expected delivery to stop once acked, got %d", len(metas))
+	}
+	for i, meta := range metas {
+		if meta.Redeliveries != i {
+			t.Errorf("Delivery %d: expected %d redeliveries, got %d", i, i, meta.Redeliveries)
+		}
+	}
+}
+
+func TestJetStreamPublishesHeaders(t *testing.T) {
+	js := newTestJetStream(t)
+	rec := &recorder{}
+
+	err := js.Subscribe(func(msg Message) {
+		rec.add(msg.Metadata())
+		msg.Ack()
+	})
+	if err != nil {
+		t.Fatal("Error subscribing:", err)
+	}
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if err := js.PublishWithHeaders("orders", []byte("{}"), map[string]string{"traceparent": traceparent}); err != nil {
+		t.Fatal("Error publishing:", err)
+	}
+
+	waitFor(t, "delivery", func() bool { return len(rec.all()) == 1 })
+	if got := rec.all()[0].Headers["traceparent"]; got != traceparent {
+		t.Errorf("Expected traceparent header %q, got %q", traceparent, got)
+	}
+}
@@ -0,0 +1,208 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"order-service/config"
+	"order-service/internal/logging"
+)
+
+const kafkaTimeout = 10 * time.Second
+
+var ErrAlreadySubscribed = errors.New("already subscribed")
+
+type Kafka struct {
+	reader  *kafka.Reader
+	writer  *kafka.Writer
+	topic   string
+	backoff []time.Duration
+
+	// ctx bounds commits as well as fetches; fetchCtx only the fetch
+	// loop, so Unsubscribe still lets in-flight messages be acked.
+	ctx       context.Context
+	cancel    context.CancelFunc
+	fetchCtx  context.Context
+	stopFetch context.CancelFunc
+	// subscribed is set by the first Subscribe, or by Close if none ran;
+	// whichever sets it owns closing done.
+	subscribed atomic.Bool
+	done       chan struct{}
+}
+
+func NewKafka(cfg *config.KafkaConfig, brokerCfg *config.BrokerConfig) (*Kafka, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("no Kafka brokers configured")
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     cfg.Brokers,
+		GroupID:     cfg.GroupID,
+		Topic:       cfg.Topic,
+		StartOffset: kafka.FirstOffset,
+	})
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+
+	slog.Info("Kafka consumer group configured", "group", cfg.GroupID, "brokers", cfg.Brokers)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fetchCtx, stopFetch := context.WithCancel(ctx)
+	return &Kafka{
+		reader:    reader,
+		writer:    writer,
+		topic:     cfg.Topic,
+		backoff:   brokerCfg.Backoff,
+		ctx:       ctx,
+		cancel:    cancel,
+		fetchCtx:  fetchCtx,
+		stopFetch: stopFetch,
+		done:      make(chan struct{}),
+	}, nil
+}
+
+func (k *Kafka) Subscribe(handler Handler) error {
+	if !k.subscribed.CompareAndSwap(false, true) {
+		return ErrAlreadySubscribed
+	}
+	go func() {
+		defer close(k.done)
+		for {
+			m, err := k.reader.FetchMessage(k.fetchCtx)
+			if err != nil {
+				if k.fetchCtx.Err() != nil {
+					return
+				}
+				slog.Error("Kafka fetch failed", logging.Err(err))
+				time.Sleep(time.Second)
+				continue
+			}
+			k.deliver(handler, m)
+		}
+	}()
+
+	slog.Info("subscribed to topic", logging.KeySubject, k.topic, "group", k.reader.Config().GroupID)
+	return nil
+}
+
+// deliver hands the message to handler until it is acked. Kafka tracks
+// only committed offsets, so redelivery of a nacked message happens here,
+// in-process, which also keeps the partition in order. A message whose
+// commit failed is redelivered after the broker backoff, so an
+// unavailable group coordinator is not retried in a tight loop.
+func (k *Kafka) deliver(handler Handler, m kafka.Message) {
+	for redeliveries := 0; ; redeliveries++ {
+		msg := &kafkaMessage{kafka: k, msg: m, redeliveries: redeliveries}
+		handler(msg)
+		if msg.acked {
+			return
+		}
+		if msg.ackFailed {
+			msg.delay = max(msg.delay, k.retryDelay(redeliveries))
+		}
+
+		select {
+		case <-time.After(msg.delay):
+		case <-k.fetchCtx.Done():
+			return
+		}
+	}
+}
+
+func (k *Kafka) retryDelay(redeliveries int) time.Duration {
+	if len(k.backoff) == 0 {
+		return time.Second
+	}
+	return k.backoff[min(redeliveries, len(k.backoff)-1)]
+}
+
+func (k *Kafka) Publish(subject string, data []byte) error {
+	return k.PublishWithHeaders(subject, data, nil)
+}
+
+func (k *Kafka) PublishWithHeaders(subject string, data []byte, headers map[string]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaTimeout)
+	defer cancel()
+
+	msg := kafka.Message{Topic: subject, Value: data}
+	for key, value := range headers {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	return k.writer.WriteMessages(ctx, msg)
+}
+
+// Unsubscribe stops fetching. The offset of a message left unacked is
+// not committed, so the group gets it again after a restart.
+func (k *Kafka) Unsubscribe() error {
+	k.stopFetch()
+	return nil
+}
+
+func (k *Kafka) Close() error {
+	k.cancel()
+	if k.subscribed.CompareAndSwap(false, true) {
+		close(k.done)
+	}
+	select {
+	case <-k.done:
+	case <-time.After(kafkaTimeout):
+	}
+
+	err := k.reader.Close()
+	if werr := k.writer.Close(); err == nil {
+		err = werr
+	}
+	slog.Info("disconnected from Kafka")
+	return err
+}
+
+type kafkaMessage struct {
+	kafka        *Kafka
+	msg          kafka.Message
+	redeliveries int
+	acked        bool
+	ackFailed    bool
+	delay        time.Duration
+}
+
+func (m *kafkaMessage) Data() []byte {
+	return m.msg.Value
+}
+
+func (m *kafkaMessage) Metadata() Metadata {
+	headers := make(map[string]string, len(m.msg.Headers))
+	for _, h := range m.msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	return Metadata{
+		Subject:      m.msg.Topic,
+		Sequence:     uint64(m.msg.Offset),
+		Redeliveries: m.redeliveries,
+		Timestamp:    m.msg.Time,
+		Headers:      headers,
+	}
+}
+
+func (m *kafkaMessage) Ack() error {
+	ctx, cancel := context.WithTimeout(m.kafka.ctx, kafkaTimeout)
+	defer cancel()
+
+	if err := m.kafka.reader.CommitMessages(ctx, m.msg); err != nil {
+		m.ackFailed = true
+		return err
+	}
+	m.acked = true
+	return nil
+}
+
+func (m *kafkaMessage) Nack(delay time.Duration) error {
+	m.delay = delay
+	return nil
+}
@@ -0,0 +1,59 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/config"
+)
+
+func newTestKafka(t *testing.T) *Kafka {
+	t.Helper()
+	// Nothing listens here; the reader and writer connect lazily.
+	k, err := NewKafka(&config.KafkaConfig{
+		Brokers: []string{"127.0.0.1:1"},
+		Topic:   "orders",
+		GroupID: "order-service-test",
+	}, &config.BrokerConfig{Backoff: []time.Duration{10 * time.Millisecond, time.Second}})
+	if err != nil {
+		t.Fatal("Error creating Kafka broker:", err)
+	}
+	return k
+}
+
+func TestKafkaCloseWithoutSubscribe(t *testing.T) {
+	k := newTestKafka(t)
+
+	start := time.Now()
+	k.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close without a subscription should not wait, took %v", elapsed)
+	}
+	if err := k.Subscribe(func(Message) {}); !errors.Is(err, ErrAlreadySubscribed) {
+		t.Errorf("Subscribe after Close should fail, got %v", err)
+	}
+}
+
+func TestKafkaSubscribeTwice(t *testing.T) {
+	k := newTestKafka(t)
+	defer k.Close()
+
+	if err := k.Subscribe(func(Message) {}); err != nil {
+		t.Fatal("Error subscribing:", err)
+	}
+	if err := k.Subscribe(func(Message) {}); !errors.Is(err, ErrAlreadySubscribed) {
+		t.Errorf("Second Subscribe should fail, got %v", err)
+	}
+}
+
+func TestKafkaRetryDelay(t *testing.T) {
+	k := newTestKafka(t)
+	defer k.Close()
+
+	for redeliveries, want := range []time.Duration{10 * time.Millisecond, time.Second, time.Second} {
+		if got := k.retryDelay(redeliveries); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", redeliveries, got, want)
+		}
+	}
+}
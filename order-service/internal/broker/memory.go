@@ -0,0 +1,227 @@
+package broker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrClosed = errors.New("broker closed")
+
+// Memory is an in-process broker for tests. Messages that are nacked, or
+// returned from the handler without an ack, are redelivered just like a
+// real broker would after its ack timeout.
+type Memory struct {
+	mu       sync.Mutex
+	queue    []*memoryMessage
+	notify   chan struct{}
+	sequence uint64
+	acked    []Metadata
+	pending  map[uint64]bool
+	closed   bool
+	done     chan struct{}
+	stop     chan struct{}
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		notify:  make(chan struct{}, 1),
+		pending: make(map[uint64]bool),
+		done:    make(chan struct{}),
+	}
+}
+
+func (m *Memory) Subscribe(handler Handler) error {
+	m.mu.Lock()
+	if m.stop == nil {
+		m.stop = make(chan struct{})
+	}
+	stop := m.stop
+	m.mu.Unlock()
+
+	go func() {
+		for {
+			msg, ok := m.next(stop)
+			if !ok {
+				return
+			}
+			handler(msg)
+			if !msg.settled() {
+				msg.Nack(0)
+			}
+		}
+	}()
+	return nil
+}
+
+// Unsubscribe stops every handler registered so far. Messages stay queued
+// for the next Subscribe.
+func (m *Memory) Unsubscribe() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	return nil
+}
+
+func (m *Memory) next(stop <-chan struct{}) (*memoryMessage, bool) {
+	for {
+		select {
+		case <-stop:
+			return nil, false
+		default:
+		}
+
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			return nil, false
+		}
+		if len(m.queue) > 0 {
+			msg := m.queue[0]
+			m.queue = m.queue[1:]
+			m.mu.Unlock()
+			return msg, true
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-m.notify:
+		case <-m.done:
+			return nil, false
+		case <-stop:
+			return nil, false
+		}
+	}
+}
+
+func (m *Memory) Publish(subject string, data []byte) error {
+	return m.PublishWithHeaders(subject, data, nil)
+}
+
+func (m *Memory) PublishWithHeaders(subject string, data []byte, headers map[string]string) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return ErrClosed
+	}
+	m.sequence++
+	msg := &memoryMessage{
+		broker: m,
+		data:   append([]byte(nil), data...),
+		meta: Metadata{
+			Subject:   subject,
+			Sequence:  m.sequence,
+			Timestamp: time.Now(),
+			Headers:   headers,
+		},
+	}
+	m.pending[msg.meta.Sequence] = true
+	m.mu.Unlock()
+
+	m.enqueue(msg)
+	return nil
+}
+
+func (m *Memory) enqueue(msg *memoryMessage) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.queue = append(m.queue, msg)
+	m.mu.Unlock()
+
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Acked returns metadata of every acknowledged message in ack order.
+func (m *Memory) Acked() []Metadata {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Metadata(nil), m.acked...)
+}
+
+// Pending returns the number of published messages not yet acked.
+func (m *Memory) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.pending)
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		close(m.done)
+	}
+	return nil
+}
+
+type memoryMessage struct {
+	broker *Memory
+	data   []byte
+	meta   Metadata
+
+	mu     sync.Mutex
+	acked  bool
+	nacked bool
+}
+
+func (msg *memoryMessage) Data() []byte {
+	return msg.data
+}
+
+func (msg *memoryMessage) Metadata() Metadata {
+	return msg.meta
+}
+
+func (msg *memoryMessage) settled() bool {
+	msg.mu.Lock()
+	defer msg.mu.Unlock()
+	return msg.acked || msg.nacked
+}
+
+func (msg *memoryMessage) Ack() error {
+	msg.mu.Lock()
+	if msg.acked || msg.nacked {
+		msg.mu.Unlock()
+		return errors.New("message already settled")
+	}
+	msg.acked = true
+	msg.mu.Unlock()
+
+	m := msg.broker
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, msg.meta.Sequence)
+	m.acked = append(m.acked, msg.meta)
+	return nil
+}
+
+func (msg *memoryMessage) Nack(delay time.Duration) error {
+	msg.mu.Lock()
+	if msg.acked || msg.nacked {
+		msg.mu.Unlock()
+		return errors.New("message already settled")
+	}
+	msg.nacked = true
+	msg.mu.Unlock()
+
+	redelivery := &memoryMessage{
+		broker: msg.broker,
+		data:   msg.data,
+		meta:   msg.meta,
+	}
+	redelivery.meta.Redeliveries++
+	time.AfterFunc(delay, func() {
+		msg.broker.enqueue(redelivery)
+	})
+	return nil
+}
@@ -0,0 +1,138 @@
+package broker
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/stan.go"
+	"order-service/config"
+	"order-service/internal/logging"
+)
+
+// stanDurableName is used when NATS_DURABLE_NAME is not set.
+const stanDurableName = "order-service-durable"
+
+type Stan struct {
+	conn      stan.Conn
+	sub       stan.Subscription
+	subject   string
+	connected *atomic.Bool
+
+	queueGroup  string
+	durableName string
+	ackWait     time.Duration
+	maxInflight int
+}
+
+func NewStan(cfg *config.NATSConfig) (*Stan, error) {
+	// NATS Streaming does not reconnect once the connection is lost, so
+	// the flag never goes back up; a restart is needed.
+	connected := new(atomic.Bool)
+	conn, err := stan.Connect(
+		cfg.ClusterID,
+		cfg.ClientID,
+		stan.NatsURL(cfg.URL),
+		stan.SetConnectionLostHandler(func(_ stan.Conn, err error) {
+			connected.Store(false)
+			slog.Error("connection lost to NATS", logging.Err(err))
+		}),
+		stan.Pings(10, 5),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	connected.Store(true)
+	slog.Info("connected to NATS Streaming", "cluster_id", cfg.ClusterID)
+
+	durableName := cfg.DurableName
+	if durableName == "" {
+		durableName = stanDurableName
+	}
+	return &Stan{
+		conn:      conn,
+		subject:   cfg.Subject,
+		connected: connected,
+
+		queueGroup:  cfg.QueueGroup,
+		durableName: durableName,
+		ackWait:     cfg.AckWait,
+		maxInflight: cfg.MaxInflight,
+	}, nil
+}
+
+func (s *Stan) Subscribe(handler Handler) error {
+	sub, err := s.conn.QueueSubscribe(
+		s.subject,
+		s.queueGroup,
+		func(msg *stan.Msg) {
+			handler(&stanMessage{msg: msg})
+		},
+		stan.DurableName(s.durableName),
+		stan.SetManualAckMode(),
+		stan.AckWait(s.ackWait),
+		stan.MaxInflight(s.maxInflight),
+	)
+	if err != nil {
+		return err
+	}
+	s.sub = sub
+
+	slog.Info("subscribed to channel", logging.KeySubject, s.subject, "group", s.queueGroup)
+	return nil
+}
+
+func (s *Stan) Publish(subject string, data []byte) error {
+	return s.conn.Publish(subject, data)
+}
+
+func (s *Stan) Connected() bool {
+	return s.connected.Load()
+}
+
+// Unsubscribe closes the subscription without unsubscribing the durable
+// queue group, so the next start resumes where this one stopped.
+func (s *Stan) Unsubscribe() error {
+	if s.sub == nil {
+		return nil
+	}
+	err := s.sub.Close()
+	s.sub = nil
+	return err
+}
+
+func (s *Stan) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	slog.Info("disconnected from NATS Streaming")
+	return s.conn.Close()
+}
+
+type stanMessage struct {
+	msg *stan.Msg
+}
+
+func (m *stanMessage) Data() []byte {
+	return m.msg.Data
+}
+
+func (m *stanMessage) Metadata() Metadata {
+	return Metadata{
+		Subject:      m.msg.Subject,
+		Sequence:     m.msg.Sequence,
+		Redeliveries: int(m.msg.RedeliveryCount),
+		Timestamp:    time.Unix(0, m.msg.Timestamp),
+	}
+}
+
+func (m *stanMessage) Ack() error {
+	return m.msg.Ack()
+}
+
+// NATS Streaming has no negative ack: an unacked message is redelivered
+// when AckWait expires, so the delay is ignored.
+func (m *stanMessage) Nack(time.Duration) error {
+	return nil
+}
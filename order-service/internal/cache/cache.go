@@ -1,65 +1,382 @@
 package cache
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"golang.org/x/sync/singleflight"
+	"order-service/config"
 	"order-service/internal/models"
 )
 
 type Database interface {
-	GetAllOrders() ([]*models.Order, error)
+	IterateOrders(ctx context.Context, since time.Time, batchSize int, fn func(batch []*models.Order) error) error
 }
 
+var errCacheFull = errors.New("cache is full")
+
 type Cache struct {
-	data map[string]*models.Order
+	data map[string]*entry
 	mu   sync.RWMutex
+
+	indexes     map[string]secondaryIndex
+	observers   []Observer
+	limits      Limits
+	windowed    bool
+	policy      policy
+	bytes       int64
+	evictions   int64
+	expirations int64
+	hits        atomic.Int64
+	misses      atomic.Int64
+
+	warmup   sync.RWMutex
+	status   WarmupStatus
+	restored atomic.Int64
+
+	loader      Loader
+	loads       singleflight.Group
+	negativeTTL time.Duration
+	negativeMu  sync.Mutex
+	negative    map[string]time.Time
+}
+
+type Option func(*Cache)
+
+// Observer is told about every order stored by Store; created reports
+// whether saving it inserted the order rather than updating it. Observers
+// run under the cache lock, in write order, so they must not block or call
+// back into the cache.
+type Observer func(order *models.Order, created bool)
+
+func WithObserver(observer Observer) Option {
+	return func(c *Cache) {
+		c.observers = append(c.observers, observer)
+	}
 }
 
-func NewCache() *Cache {
-	return &Cache{
-		data: make(map[string]*models.Order),
+type WarmupStatus struct {
+	Ready      bool      `json:"ready"`
+	InProgress bool      `json:"in_progress"`
+	Loaded     int64     `json:"loaded"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func NewCache(opts ...Option) *Cache {
+	c := &Cache{
+		data:     make(map[string]*entry),
+		indexes:  newIndexes(),
+		negative: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	if !c.limits.bounded() {
+		c.policy = nil
+	}
+	return c
 }
 
+// Set stores order unless the cache already holds a newer version of it.
 func (c *Cache) Set(order *models.Order) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.data[order.OrderUID] = order
+	c.set(order)
 }
 
-func (c *Cache) Get(orderUID string) (*models.Order, bool) {
+// Store is Set for an order that was just saved to the database, and
+// tells observers about it. Whether the order is new comes from the save,
+// not from the cache, which may have evicted or never loaded it.
+func (c *Cache) Store(order *models.Order, created bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.set(order) {
+		return
+	}
+	for _, observer := range c.observers {
+		observer(order, created)
+	}
+}
+
+func (c *Cache) set(order *models.Order) bool {
+	if e, ok := c.data[order.OrderUID]; ok && e.order.Version > order.Version {
+		return false
+	}
+	c.insert(order)
+	c.forgetMiss(order.OrderUID)
+	return true
+}
+
+// Replace stores order even if the cache holds a newer version, without
+// notifying observers. It is meant for repairing drift from the database.
+func (c *Cache) Replace(order *models.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.insert(order)
+	c.forgetMiss(order.OrderUID)
+}
+
+// Delete removes the order and reports whether it was cached.
+func (c *Cache) Delete(orderUID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.data[orderUID]
+	if ok {
+		c.remove(e)
+	}
+	return ok
+}
+
+// Peek returns the cached order without updating eviction state or
+// falling back to the loader.
+func (c *Cache) Peek(orderUID string) (*models.Order, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	order, exists := c.data[orderUID]
-	return order, exists
+	e, ok := c.data[orderUID]
+	if !ok || e.expired(time.Now()) {
+		return nil, false
+	}
+	return e.order, true
+}
+
+// Bounded reports whether the cache may hold only part of the orders
+// because of eviction, TTL or a restore window.
+func (c *Cache) Bounded() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.limits.bounded() || c.limits.TTL > 0 || c.windowed
+}
+
+// Get returns the cached order. On a miss it falls back to the loader,
+// if one is configured, and caches the result.
+func (c *Cache) Get(orderUID string) (*models.Order, bool) {
+	return c.GetContext(context.Background(), orderUID)
+}
+
+// GetContext is Get with a context that bounds the fallback to the
+// loader. An order the loader could not load in time is a miss.
+func (c *Cache) GetContext(ctx context.Context, orderUID string) (*models.Order, bool) {
+	if order, ok := c.lookup(orderUID); ok {
+		c.hits.Add(1)
+		return order, true
+	}
+	c.misses.Add(1)
+	if c.loader == nil {
+		return nil, false
+	}
+	return c.load(ctx, orderUID)
+}
+
+func (c *Cache) lookup(orderUID string) (*models.Order, bool) {
+	// Without a policy there is nothing to update on a hit, so only an
+	// expired entry, possibly stored before TTL was turned off, needs
+	// the write lock.
+	c.mu.RLock()
+	if c.policy == nil {
+		e, ok := c.data[orderUID]
+		if !ok {
+			c.mu.RUnlock()
+			return nil, false
+		}
+		if !e.expired(time.Now()) {
+			c.mu.RUnlock()
+			return e.order, true
+		}
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.data[orderUID]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(time.Now()) {
+		c.remove(e)
+		c.expirations++
+		return nil, false
+	}
+	if c.policy != nil {
+		c.policy.touch(e)
+	}
+	return e.order, true
 }
 
 func (c *Cache) GetAll() map[string]*models.Order {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	now := time.Now()
 	result := make(map[string]*models.Order, len(c.data))
-	for k, v := range c.data {
-		result[k] = v
+	for k, e := range c.data {
+		if !e.expired(now) {
+			result[k] = e.order
+		}
 	}
 	return result
 }
 
-func (c *Cache) RestoreFromDB(db Database) error {
-	log.Println("Восстановление кэша из БД...")
+// Range calls fn for every live order, without copying the cache, until
+// fn returns false. The read lock is held throughout, so fn must be quick
+// and must not call back into the cache.
+func (c *Cache) Range(fn func(order *models.Order) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	orders, err := db.GetAllOrders()
-	if err != nil {
-		return err
+	now := time.Now()
+	for _, e := range c.data {
+		if e.expired(now) {
+			continue
+		}
+		if !fn(e.order) {
+			return
+		}
 	}
+}
 
-	c.mu.Lock()
-	for _, order := range orders {
-		c.data[order.OrderUID] = order
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Stats{
+		Entries:     len(c.data),
+		Bytes:       c.bytes,
+		MaxEntries:  c.limits.MaxEntries,
+		MaxBytes:    c.limits.MaxBytes,
+		Policy:      c.limits.Policy,
+		Evictions:   c.evictions,
+		Expirations: c.expirations,
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+	}
+}
+
+// insert replaces any entry for the order, first evicting others until
+// the new entry fits within the limits. The caller must hold c.mu.
+func (c *Cache) insert(order *models.Order) *entry {
+	if e, ok := c.data[order.OrderUID]; ok {
+		c.remove(e)
+	}
+
+	e := &entry{order: order, size: estimateSize(order)}
+	if c.limits.TTL > 0 {
+		e.expires = time.Now().Add(c.limits.TTL)
+	}
+
+	if c.policy != nil {
+		for c.exceeds(1, e.size) {
+			victim := c.policy.victim()
+			if victim == nil {
+				break
+			}
+			c.remove(victim)
+			c.evictions++
+		}
+		c.policy.add(e)
+	}
+	c.data[order.OrderUID] = e
+	c.bytes += e.size
+	c.addToIndexes(order)
+	return e
+}
+
+func (c *Cache) remove(e *entry) {
+	delete(c.data, e.order.OrderUID)
+	c.bytes -= e.size
+	c.removeFromIndexes(e.order)
+	if c.policy != nil {
+		c.policy.remove(e)
+	}
+}
+
+func (c *Cache) exceeds(entries int, bytes int64) bool {
+	return (c.limits.MaxEntries > 0 && len(c.data)+entries > c.limits.MaxEntries) ||
+		(c.limits.MaxBytes > 0 && c.bytes+bytes > c.limits.MaxBytes)
+}
+
+// RestoreFromDB loads orders page by page so memory never holds more than
+// one batch beyond the cache itself. It is safe to run in the background:
+// entries written by Set meanwhile are not overwritten by older versions.
+// Cancelling ctx stops it with the orders loaded so far.
+func (c *Cache) RestoreFromDB(ctx context.Context, db Database, cfg *config.CacheConfig) error {
+	var since time.Time
+	if cfg.RestoreDays > 0 {
+		since = time.Now().AddDate(0, 0, -cfg.RestoreDays)
 	}
+	slog.Info("restoring cache from database", "since", since, "batch_size", cfg.RestoreBatchSize)
+
+	c.mu.Lock()
+	c.windowed = !since.IsZero()
 	c.mu.Unlock()
 
-	log.Printf("Кэш восстановлен: загружено %d заказов", len(orders))
+	c.warmup.Lock()
+	c.status = WarmupStatus{InProgress: true, StartedAt: time.Now()}
+	c.warmup.Unlock()
+	c.restored.Store(0)
+
+	err := db.IterateOrders(ctx, since, cfg.RestoreBatchSize, func(batch []*models.Order) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, order := range batch {
+			// Orders arrive newest first, so once a bounded cache is full
+			// the rest would only evict newer entries. Each one counts as
+			// used before everything already cached, leaving the oldest
+			// orders to be evicted first.
+			entries, bytes := 1, estimateSize(order)
+			if e, ok := c.data[order.OrderUID]; ok {
+				if e.order.Version >= order.Version {
+					continue
+				}
+				entries, bytes = 0, bytes-e.size
+			}
+			if c.policy != nil && c.exceeds(entries, bytes) {
+				return errCacheFull
+			}
+			e := c.insert(order)
+			if c.policy != nil {
+				c.policy.demote(e)
+			}
+			c.restored.Add(1)
+		}
+		return nil
+	})
+	if errors.Is(err, errCacheFull) {
+		slog.Info("cache restore stopped at the cache limits")
+		err = nil
+	}
+
+	c.warmup.Lock()
+	c.status.Ready = true
+	c.status.InProgress = false
+	c.status.FinishedAt = time.Now()
+	if err != nil {
+		c.status.Error = err.Error()
+	}
+	c.warmup.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	slog.Info("cache restored", "orders", c.restored.Load())
 	return nil
 }
+
+func (c *Cache) Ready() bool {
+	c.warmup.RLock()
+	defer c.warmup.RUnlock()
+	return c.status.Ready
+}
+
+func (c *Cache) WarmupStatus() WarmupStatus {
+	c.warmup.RLock()
+	defer c.warmup.RUnlock()
+	status := c.status
+	status.Loaded = c.restored.Load()
+	return status
+}
@@ -1,9 +1,13 @@
 package cache
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"order-service/config"
 	"order-service/internal/models"
 )
 
@@ -56,6 +60,176 @@ func TestCacheGetAll(t *testing.T) {
 	}
 }
 
+func TestCacheObserver(t *testing.T) {
+	var changes []string
+	cache := NewCache(WithObserver(func(order *models.Order, created bool) {
+		changes = append(changes, fmt.Sprintf("%s:%d:%t", order.OrderUID, order.Version, created))
+	}))
+
+	cache.Store(&models.Order{OrderUID: "A", Version: 1}, true)
+	cache.Store(&models.Order{OrderUID: "A", Version: 2}, false)
+	cache.Store(&models.Order{OrderUID: "A", Version: 1}, false)
+	cache.Set(&models.Order{OrderUID: "A", Version: 3})
+
+	want := []string{"A:1:true", "A:2:false"}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("Observed %v, want %v", changes, want)
+	}
+}
+
+func TestCacheReplaceAndDelete(t *testing.T) {
+	cache := NewCache()
+	cache.Set(&models.Order{OrderUID: "A", Version: 2, TrackNumber: "NEW"})
+
+	cache.Replace(&models.Order{OrderUID: "A", Version: 1, TrackNumber: "OLD"})
+	if order, _ := cache.Peek("A"); order.Version != 1 {
+		t.Errorf("Replace should store an older version, got %d", order.Version)
+	}
+	if orders := cache.FindBy(IndexTrackNumber, "NEW"); len(orders) != 0 {
+		t.Error("Replace should update indexes")
+	}
+
+	if !cache.Delete("A") || cache.Delete("A") {
+		t.Error("Delete should report whether the order was cached")
+	}
+	if _, ok := cache.Peek("A"); ok {
+		t.Error("Deleted order should be gone")
+	}
+}
+
+func TestCacheRange(t *testing.T) {
+	cache := NewCache()
+	for _, uid := range []string{"A", "B", "C"} {
+		cache.Set(&models.Order{OrderUID: uid})
+	}
+
+	seen := 0
+	cache.Range(func(order *models.Order) bool {
+		seen++
+		return true
+	})
+	if seen != 3 {
+		t.Errorf("Expected Range to visit 3 orders, got %d", seen)
+	}
+
+	seen = 0
+	cache.Range(func(order *models.Order) bool {
+		seen++
+		return false
+	})
+	if seen != 1 {
+		t.Errorf("Range should stop when fn returns false, visited %d", seen)
+	}
+}
+
+func TestCacheSetOnlyMovesForward(t *testing.T) {
+	cache := NewCache()
+
+	cache.Set(&models.Order{OrderUID: "ORDER_V", TrackNumber: "V2", Version: 2})
+	cache.Set(&models.Order{OrderUID: "ORDER_V", TrackNumber: "V1", Version: 1})
+
+	retrieved, _ := cache.Get("ORDER_V")
+	if retrieved.Version != 2 || retrieved.TrackNumber != "V2" {
+		t.Errorf("Older version must not replace newer, got version %d", retrieved.Version)
+	}
+
+	cache.Set(&models.Order{OrderUID: "ORDER_V", TrackNumber: "V3", Version: 3})
+	retrieved, _ = cache.Get("ORDER_V")
+	if retrieved.Version != 3 {
+		t.Errorf("Expected version 3, got %d", retrieved.Version)
+	}
+}
+
+type mockDatabase struct {
+	orders  []*models.Order
+	batches int
+	err     error
+	onBatch func()
+}
+
+func (m *mockDatabase) IterateOrders(ctx context.Context, since time.Time, batchSize int, fn func(batch []*models.Order) error) error {
+	for start := 0; start < len(m.orders); start += batchSize {
+		end := start + batchSize
+		if end > len(m.orders) {
+			end = len(m.orders)
+		}
+		m.batches++
+		if err := fn(m.orders[start:end]); err != nil {
+			return err
+		}
+		if m.onBatch != nil {
+			m.onBatch()
+		}
+	}
+	return m.err
+}
+
+func TestCacheRestoreFromDBInBatches(t *testing.T) {
+	cache := NewCache()
+	db := &mockDatabase{}
+	for i := 0; i < 25; i++ {
+		db.orders = append(db.orders, &models.Order{OrderUID: string(rune('A' + i))})
+	}
+
+	var inProgress []bool
+	db.onBatch = func() {
+		status := cache.WarmupStatus()
+		inProgress = append(inProgress, status.InProgress && !status.Ready)
+	}
+
+	if cache.Ready() {
+		t.Fatal("Cache must not be ready before restore")
+	}
+	if err := cache.RestoreFromDB(context.Background(), db, &config.CacheConfig{RestoreBatchSize: 10}); err != nil {
+		t.Fatal("Restore failed:", err)
+	}
+
+	if db.batches != 3 {
+		t.Errorf("Expected 3 batches, got %d", db.batches)
+	}
+	for i, v := range inProgress {
+		if !v {
+			t.Errorf("Batch %d: warm-up should be in progress and not ready", i)
+		}
+	}
+	status := cache.WarmupStatus()
+	if !status.Ready || status.InProgress || status.Loaded != 25 {
+		t.Errorf("Unexpected status after restore: %+v", status)
+	}
+	if len(cache.GetAll()) != 25 {
+		t.Errorf("Expected 25 orders, got %d", len(cache.GetAll()))
+	}
+}
+
+func TestCacheRestoreKeepsNewerVersions(t *testing.T) {
+	cache := NewCache()
+	cache.Set(&models.Order{OrderUID: "ORDER_1", Version: 5})
+
+	db := &mockDatabase{orders: []*models.Order{{OrderUID: "ORDER_1", Version: 4}}}
+	if err := cache.RestoreFromDB(context.Background(), db, &config.CacheConfig{RestoreBatchSize: 10}); err != nil {
+		t.Fatal("Restore failed:", err)
+	}
+
+	order, _ := cache.Get("ORDER_1")
+	if order.Version != 5 {
+		t.Errorf("Restore must not overwrite newer version, got %d", order.Version)
+	}
+}
+
+func TestCacheRestoreErrorMarksReady(t *testing.T) {
+	cache := NewCache()
+	db := &mockDatabase{err: errors.New("connection reset")}
+
+	if err := cache.RestoreFromDB(context.Background(), db, &config.CacheConfig{RestoreBatchSize: 10}); err == nil {
+		t.Fatal("Expected restore error")
+	}
+
+	status := cache.WarmupStatus()
+	if !status.Ready || status.Error == "" {
+		t.Errorf("Failed restore should finish with error recorded, got %+v", status)
+	}
+}
+
 func TestCacheConcurrentAccess(t *testing.T) {
 	cache := NewCache()
 
@@ -97,3 +271,17 @@ func BenchmarkCacheSet(b *testing.B) {
 		cache.Set(order)
 	}
 }
+
+func TestCacheHitsAndMisses(t *testing.T) {
+	cache := NewCache()
+	cache.Set(&models.Order{OrderUID: "A"})
+
+	cache.Get("A")
+	cache.Get("A")
+	cache.Get("B")
+	cache.Peek("B")
+
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Expected 2 hits and 1 miss, got %+v", stats)
+	}
+}
@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"log/slog"
+	"time"
+
+	"order-service/internal/models"
+)
+
+const (
+	PolicyLRU = "lru"
+	PolicyLFU = "lfu"
+)
+
+type Limits struct {
+	MaxEntries int
+	MaxBytes   int64
+	Policy     string
+	TTL        time.Duration
+}
+
+func (l Limits) bounded() bool {
+	return l.MaxEntries > 0 || l.MaxBytes > 0
+}
+
+type Stats struct {
+	Entries     int    `json:"entries"`
+	Bytes       int64  `json:"bytes"`
+	MaxEntries  int    `json:"max_entries"`
+	MaxBytes    int64  `json:"max_bytes"`
+	Policy      string `json:"policy"`
+	Evictions   int64  `json:"evictions"`
+	Expirations int64  `json:"expirations"`
+	Hits        int64  `json:"hits"`
+	Misses      int64  `json:"misses"`
+}
+
+// WithLimits bounds the cache by entry count and/or approximate size in
+// bytes, evicting by Policy, and expires entries older than TTL.
+func WithLimits(limits Limits) Option {
+	return func(c *Cache) {
+		c.limits = limits
+		c.policy = newPolicy(limits.Policy)
+	}
+}
+
+// SetLimits changes the limits of a running cache, evicting entries
+// until it fits the new ones. A new TTL applies to orders stored from
+// now on. Switching policy restarts usage tracking from scratch.
+func (c *Cache) SetLimits(limits Limits) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.limits
+	c.limits = limits
+	switch {
+	case !limits.bounded():
+		c.policy = nil
+	case c.policy == nil || limits.Policy != previous.Policy:
+		c.policy = newPolicy(limits.Policy)
+		for _, e := range c.data {
+			c.policy.add(e)
+		}
+	}
+
+	if c.policy == nil {
+		return
+	}
+	for c.exceeds(0, 0) {
+		victim := c.policy.victim()
+		if victim == nil {
+			break
+		}
+		c.remove(victim)
+		c.evictions++
+	}
+}
+
+// Run removes expired orders periodically until ctx is done. Reads skip
+// them anyway, but without the sweep a cache with a TTL and no size
+// limits would hold on to them forever.
+func (c *Cache) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.sweepInterval()):
+		}
+		c.removeExpired()
+	}
+}
+
+// sweepInterval is half the TTL, so an expired order lingers for at most
+// half its lifetime, kept between a second and a minute. Without a TTL
+// the sweep still runs each minute in case a reload sets one.
+func (c *Cache) sweepInterval() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.limits.TTL <= 0 {
+		return time.Minute
+	}
+	return min(max(c.limits.TTL/2, time.Second), time.Minute)
+}
+
+func (c *Cache) removeExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, e := range c.data {
+		if e.expired(now) {
+			c.remove(e)
+			c.expirations++
+		}
+	}
+}
+
+type entry struct {
+	order   *models.Order
+	size    int64
+	expires time.Time
+
+	elem  *list.Element
+	freq  int
+	tick  int64
+	index int
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+type policy interface {
+	add(e *entry)
+	touch(e *entry)
+	remove(e *entry)
+	// demote marks e as used before every other entry.
+	demote(e *entry)
+	victim() *entry
+}
+
+func newPolicy(name string) policy {
+	switch name {
+	case PolicyLFU:
+		return &lfuPolicy{}
+	case PolicyLRU, "":
+		return &lruPolicy{list: list.New()}
+	default:
+		slog.Warn("unknown eviction policy, using LRU", "policy", name)
+		return &lruPolicy{list: list.New()}
+	}
+}
+
+type lruPolicy struct {
+	list *list.List
+}
+
+func (p *lruPolicy) add(e *entry) {
+	e.elem = p.list.PushFront(e)
+}
+
+func (p *lruPolicy) touch(e *entry) {
+	p.list.MoveToFront(e.elem)
+}
+
+func (p *lruPolicy) remove(e *entry) {
+	p.list.Remove(e.elem)
+}
+
+func (p *lruPolicy) demote(e *entry) {
+	p.list.MoveToBack(e.elem)
+}
+
+func (p *lruPolicy) victim() *entry {
+	if back := p.list.Back(); back != nil {
+		return back.Value.(*entry)
+	}
+	return nil
+}
+
+// lfuPolicy evicts the least frequently used entry, the least recently
+// used one among equals.
+type lfuPolicy struct {
+	entries lfuHeap
+	ticks   int64
+	demoted int64
+}
+
+func (p *lfuPolicy) add(e *entry) {
+	p.ticks++
+	e.freq = 1
+	e.tick = p.ticks
+	heap.Push(&p.entries, e)
+}
+
+func (p *lfuPolicy) touch(e *entry) {
+	p.ticks++
+	e.freq++
+	e.tick = p.ticks
+	heap.Fix(&p.entries, e.index)
+}
+
+func (p *lfuPolicy) remove(e *entry) {
+	heap.Remove(&p.entries, e.index)
+}
+
+func (p *lfuPolicy) demote(e *entry) {
+	p.demoted--
+	e.tick = p.demoted
+	heap.Fix(&p.entries, e.index)
+}
+
+func (p *lfuPolicy) victim() *entry {
+	if len(p.entries) == 0 {
+		return nil
+	}
+	return p.entries[0]
+}
+
+type lfuHeap []*entry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+// estimateSize approximates the memory held by an order: string bytes
+// plus a fixed overhead per struct.
+func estimateSize(order *models.Order) int64 {
+	const structOverhead = 256
+
+	size := int64(structOverhead*3 + len(order.OrderUID) + len(order.TrackNumber) + len(order.Entry) +
+		len(order.Locale) + len(order.InternalSignature) + len(order.CustomerID) +
+		len(order.DeliveryService) + len(order.Shardkey) + len(order.OofShard))
+
+	d := order.Delivery
+	size += int64(len(d.Name) + len(d.Phone) + len(d.Zip) + len(d.City) + len(d.Address) + len(d.Region) + len(d.Email))
+
+	p := order.Payment
+	size += int64(len(p.Transaction) + len(p.RequestID) + len(p.Currency) + len(p.Provider) + len(p.Bank))
+
+	for _, item := range order.Items {
+		size += int64(structOverhead + len(item.TrackNumber) + len(item.Rid) + len(item.Name) + len(item.Size) + len(item.Brand))
+	}
+	return size
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/models"
+)
+
+func TestCacheLRUEviction(t *testing.T) {
+	cache := NewCache(WithLimits(Limits{MaxEntries: 2, Policy: PolicyLRU}))
+
+	cache.Set(&models.Order{OrderUID: "A"})
+	cache.Set(&models.Order{OrderUID: "B"})
+	cache.Get("A")
+	cache.Set(&models.Order{OrderUID: "C"})
+
+	if _, exists := cache.Get("B"); exists {
+		t.Error("Least recently used order B should be evicted")
+	}
+	for _, uid := range []string{"A", "C"} {
+		if _, exists := cache.Get(uid); !exists {
+			t.Errorf("Order %s should stay in cache", uid)
+		}
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCacheLFUEviction(t *testing.T) {
+	cache := NewCache(WithLimits(Limits{MaxEntries: 2, Policy: PolicyLFU}))
+
+	cache.Set(&models.Order{OrderUID: "A"})
+	cache.Set(&models.Order{OrderUID: "B"})
+	cache.Get("A")
+	cache.Get("A")
+	cache.Get("B")
+	cache.Set(&models.Order{OrderUID: "C"})
+
+	if _, exists := cache.Get("B"); exists {
+		t.Error("Least frequently used order B should be evicted")
+	}
+	if _, exists := cache.Get("A"); !exists {
+		t.Error("Frequently used order A should stay in cache")
+	}
+}
+
+func TestCacheByteBudget(t *testing.T) {
+	order := &models.Order{OrderUID: "A"}
+	budget := estimateSize(order)*3 + estimateSize(order)/2
+	cache := NewCache(WithLimits(Limits{MaxBytes: budget}))
+
+	for _, uid := range []string{"A", "B", "C", "D", "E"} {
+		cache.Set(&models.Order{OrderUID: uid})
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 3 || stats.Bytes > budget || stats.Evictions != 2 {
+		t.Errorf("Expected 3 entries within %d bytes, got %+v", budget, stats)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cache := NewCache(WithLimits(Limits{TTL: 20 * time.Millisecond}))
+
+	cache.Set(&models.Order{OrderUID: "A"})
+	if _, exists := cache.Get("A"); !exists {
+		t.Fatal("Order should exist before TTL")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, exists := cache.Get("A"); exists {
+		t.Error("Order should expire after TTL")
+	}
+	if len(cache.GetAll()) != 0 {
+		t.Error("GetAll must skip expired orders")
+	}
+	if stats := cache.Stats(); stats.Expirations != 1 {
+		t.Errorf("Expected 1 expiration, got %d", stats.Expirations)
+	}
+}
+
+func TestCacheRestoreStopsWhenFull(t *testing.T) {
+	cache := NewCache(WithLimits(Limits{MaxEntries: 5}))
+	db := &mockDatabase{}
+	for i := 0; i < 20; i++ {
+		db.orders = append(db.orders, &models.Order{OrderUID: string(rune('A' + i))})
+	}
+
+	if err := cache.RestoreFromDB(context.Background(), db, &config.CacheConfig{RestoreBatchSize: 4}); err != nil {
+		t.Fatal("Restore failed:", err)
+	}
+
+	for _, uid := range []string{"A", "B", "C", "D", "E"} {
+		if _, exists := cache.Get(uid); !exists {
+			t.Errorf("Newest order %s should be restored", uid)
+		}
+	}
+	if stats := cache.Stats(); stats.Entries != 5 || stats.Evictions != 0 {
+		t.Errorf("Restore should stop at the limit without evicting, got %+v", stats)
+	}
+}
+
+func TestCacheSetLimits(t *testing.T) {
+	cache := NewCache(WithLimits(Limits{MaxEntries: 10, Policy: PolicyLRU}))
+	for _, uid := range []string{"A", "B", "C", "D"} {
+		cache.Set(&models.Order{OrderUID: uid})
+	}
+	cache.Get("A")
+
+	cache.SetLimits(Limits{MaxEntries: 2, Policy: PolicyLRU})
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Evictions != 2 {
+		t.Errorf("Lowering the limit should evict down to it, got %+v", stats)
+	}
+	for _, uid := range []string{"A", "D"} {
+		if _, exists := cache.Get(uid); !exists {
+			t.Errorf("Recently used order %s should stay in cache", uid)
+		}
+	}
+
+	cache.SetLimits(Limits{MaxEntries: 2, Policy: PolicyLFU})
+	cache.Get("D")
+	cache.Set(&models.Order{OrderUID: "E"})
+	if _, exists := cache.Get("A"); exists {
+		t.Error("After switching to LFU the least frequently used order A should be evicted")
+	}
+
+	cache.SetLimits(Limits{})
+	for _, uid := range []string{"F", "G", "H"} {
+		cache.Set(&models.Order{OrderUID: uid})
+	}
+	if cache.Bounded() || cache.Stats().Entries != 5 {
+		t.Errorf("Removing the limits should stop eviction, got %+v", cache.Stats())
+	}
+}
+
+func TestCacheSweepsExpiredOrders(t *testing.T) {
+	cache := NewCache(WithLimits(Limits{TTL: 20 * time.Millisecond}))
+	cache.Set(&models.Order{OrderUID: "A", TrackNumber: "TRACK"})
+	cache.Set(&models.Order{OrderUID: "B", TrackNumber: "TRACK"})
+
+	time.Sleep(30 * time.Millisecond)
+	live := &models.Order{OrderUID: "C", TrackNumber: "TRACK"}
+	cache.Set(live)
+	cache.removeExpired()
+
+	stats := cache.Stats()
+	if stats.Entries != 1 || stats.Expirations != 2 || stats.Bytes != estimateSize(live) {
+		t.Errorf("Expired orders should be removed without being read, got %+v", stats)
+	}
+	if orders := cache.FindBy(IndexTrackNumber, "TRACK"); len(orders) != 1 {
+		t.Errorf("Expired orders should leave the indexes, got %d", len(orders))
+	}
+}
+
+func TestCacheRestoreKeepsNewestOrders(t *testing.T) {
+	for _, policy := range []string{PolicyLRU, PolicyLFU} {
+		cache := NewCache(WithLimits(Limits{MaxEntries: 3, Policy: policy}))
+		// IterateOrders yields the newest orders first.
+		db := &mockDatabase{orders: []*models.Order{{OrderUID: "C"}, {OrderUID: "B"}, {OrderUID: "A"}}}
+		if err := cache.RestoreFromDB(context.Background(), db, &config.CacheConfig{RestoreBatchSize: 10}); err != nil {
+			t.Fatal("Restore failed:", err)
+		}
+
+		cache.Set(&models.Order{OrderUID: "D"})
+		if _, exists := cache.Peek("A"); exists {
+			t.Errorf("%s: the oldest restored order A should be evicted first", policy)
+		}
+		for _, uid := range []string{"B", "C", "D"} {
+			if _, exists := cache.Peek(uid); !exists {
+				t.Errorf("%s: order %s should stay in cache", policy, uid)
+			}
+		}
+	}
+}
+
+func TestCacheExpiresAfterTTLDisabled(t *testing.T) {
+	cache := NewCache(WithLimits(Limits{TTL: 20 * time.Millisecond}))
+	cache.Set(&models.Order{OrderUID: "A"})
+	cache.SetLimits(Limits{})
+
+	time.Sleep(30 * time.Millisecond)
+	if _, exists := cache.Get("A"); exists {
+		t.Error("An order stored under a TTL should still expire after TTL is turned off")
+	}
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Expirations != 1 {
+		t.Errorf("Expected the expired order removed, got %+v", stats)
+	}
+}
+
+func TestCacheRestoreReplacesWhenFull(t *testing.T) {
+	cache := NewCache(WithLimits(Limits{MaxEntries: 2, Policy: PolicyLRU}))
+	cache.Set(&models.Order{OrderUID: "A"})
+	cache.Set(&models.Order{OrderUID: "B"})
+
+	db := &mockDatabase{orders: []*models.Order{{OrderUID: "A", Version: 1}, {OrderUID: "B", Version: 1}}}
+	if err := cache.RestoreFromDB(context.Background(), db, &config.CacheConfig{RestoreBatchSize: 10}); err != nil {
+		t.Fatal("Restore failed:", err)
+	}
+	for _, uid := range []string{"A", "B"} {
+		if order, _ := cache.Peek(uid); order == nil || order.Version != 1 {
+			t.Errorf("Restore should replace %s with the newer version even when the cache is full", uid)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"sort"
+	"time"
+
+	"order-service/internal/models"
+)
+
+const (
+	IndexTrackNumber = "track_number"
+	IndexCustomerID  = "customer_id"
+	IndexTransaction = "transaction"
+	IndexRid         = "rid"
+)
+
+var indexNames = []string{IndexTrackNumber, IndexCustomerID, IndexTransaction, IndexRid}
+
+// secondaryIndex maps an indexed value to the set of order UIDs having it.
+type secondaryIndex map[string]map[string]struct{}
+
+func newIndexes() map[string]secondaryIndex {
+	indexes := make(map[string]secondaryIndex, len(indexNames))
+	for _, name := range indexNames {
+		indexes[name] = make(secondaryIndex)
+	}
+	return indexes
+}
+
+func indexValues(order *models.Order, name string) []string {
+	switch name {
+	case IndexTrackNumber:
+		return []string{order.TrackNumber}
+	case IndexCustomerID:
+		return []string{order.CustomerID}
+	case IndexTransaction:
+		return []string{order.Payment.Transaction}
+	case IndexRid:
+		rids := make([]string, 0, len(order.Items))
+		for _, item := range order.Items {
+			rids = append(rids, item.Rid)
+		}
+		return rids
+	}
+	return nil
+}
+
+// The caller must hold c.mu for both index updates.
+func (c *Cache) addToIndexes(order *models.Order) {
+	for name, idx := range c.indexes {
+		for _, value := range indexValues(order, name) {
+			if value == "" {
+				continue
+			}
+			uids, ok := idx[value]
+			if !ok {
+				uids = make(map[string]struct{})
+				idx[value] = uids
+			}
+			uids[order.OrderUID] = struct{}{}
+		}
+	}
+}
+
+func (c *Cache) removeFromIndexes(order *models.Order) {
+	for name, idx := range c.indexes {
+		for _, value := range indexValues(order, name) {
+			if uids, ok := idx[value]; ok {
+				delete(uids, order.OrderUID)
+				if len(uids) == 0 {
+					delete(idx, value)
+				}
+			}
+		}
+	}
+}
+
+// FindBy returns cached orders whose index field equals value, newest
+// first. Unknown index names return nil.
+func (c *Cache) FindBy(index, value string) []*models.Order {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	idx, ok := c.indexes[index]
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	orders := make([]*models.Order, 0, len(idx[value]))
+	for uid := range idx[value] {
+		if e, ok := c.data[uid]; ok && !e.expired(now) {
+			orders = append(orders, e.order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].DateCreated.Equal(orders[j].DateCreated) {
+			return orders[i].DateCreated.After(orders[j].DateCreated)
+		}
+		return orders[i].OrderUID < orders[j].OrderUID
+	})
+	return orders
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"testing"
+
+	"order-service/internal/models"
+)
+
+func indexedOrder(uid, track, customer string, version int64, rids ...string) *models.Order {
+	order := &models.Order{
+		OrderUID:    uid,
+		TrackNumber: track,
+		CustomerID:  customer,
+		Version:     version,
+		Payment:     models.Payment{Transaction: "tx-" + uid},
+	}
+	for _, rid := range rids {
+		order.Items = append(order.Items, models.Item{TrackNumber: track, Rid: rid})
+	}
+	return order
+}
+
+func uidsOf(orders []*models.Order) map[string]bool {
+	uids := make(map[string]bool, len(orders))
+	for _, order := range orders {
+		uids[order.OrderUID] = true
+	}
+	return uids
+}
+
+func TestCacheFindBy(t *testing.T) {
+	cache := NewCache()
+	cache.Set(indexedOrder("A", "TRACK1", "alice", 0, "rid-a1", "rid-a2"))
+	cache.Set(indexedOrder("B", "TRACK1", "bob", 0, "rid-b1"))
+	cache.Set(indexedOrder("C", "TRACK2", "alice", 0))
+
+	tests := []struct {
+		index, value string
+		want         []string
+	}{
+		{IndexTrackNumber, "TRACK1", []string{"A", "B"}},
+		{IndexCustomerID, "alice", []string{"A", "C"}},
+		{IndexTransaction, "tx-B", []string{"B"}},
+		{IndexRid, "rid-a2", []string{"A"}},
+		{IndexRid, "missing", nil},
+		{"unknown", "TRACK1", nil},
+	}
+	for _, tt := range tests {
+		got := uidsOf(cache.FindBy(tt.index, tt.value))
+		if len(got) != len(tt.want) {
+			t.Errorf("FindBy(%s, %s) = %v, want %v", tt.index, tt.value, got, tt.want)
+			continue
+		}
+		for _, uid := range tt.want {
+			if !got[uid] {
+				t.Errorf("FindBy(%s, %s) = %v, want %v", tt.index, tt.value, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestCacheIndexFollowsUpdates(t *testing.T) {
+	cache := NewCache()
+	cache.Set(indexedOrder("A", "TRACK1", "alice", 1, "rid-1"))
+	cache.Set(indexedOrder("A", "TRACK2", "alice", 2, "rid-2"))
+
+	if orders := cache.FindBy(IndexTrackNumber, "TRACK1"); len(orders) != 0 {
+		t.Errorf("Old track number should be unindexed, got %d orders", len(orders))
+	}
+	if orders := cache.FindBy(IndexRid, "rid-2"); len(orders) != 1 || orders[0].Version != 2 {
+		t.Errorf("New rid should point at version 2, got %v", orders)
+	}
+	if len(cache.indexes[IndexTrackNumber]) != 1 || len(cache.indexes[IndexRid]) != 1 {
+		t.Errorf("Stale index keys left behind: %v", cache.indexes)
+	}
+}
+
+func TestCacheIndexFollowsEviction(t *testing.T) {
+	cache := NewCache(WithLimits(Limits{MaxEntries: 1}))
+	cache.Set(indexedOrder("A", "TRACK1", "alice", 0))
+	cache.Set(indexedOrder("B", "TRACK2", "bob", 0))
+
+	if orders := cache.FindBy(IndexCustomerID, "alice"); len(orders) != 0 {
+		t.Errorf("Evicted order should be unindexed, got %d orders", len(orders))
+	}
+	if orders := cache.FindBy(IndexCustomerID, "bob"); len(orders) != 1 {
+		t.Errorf("Expected 1 order for bob, got %d", len(orders))
+	}
+}
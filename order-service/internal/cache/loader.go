@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"order-service/internal/database"
+	"order-service/internal/logging"
+	"order-service/internal/models"
+)
+
+const maxNegativeEntries = 10000
+
+type Loader interface {
+	GetOrder(ctx context.Context, orderUID string) (*models.Order, error)
+}
+
+// WithLoader enables read-through: misses are loaded with loader, and
+// orders the loader does not know are remembered for negativeTTL.
+func WithLoader(loader Loader, negativeTTL time.Duration) Option {
+	return func(c *Cache) {
+		c.loader = loader
+		c.negativeTTL = negativeTTL
+	}
+}
+
+func (c *Cache) load(ctx context.Context, orderUID string) (*models.Order, bool) {
+	if c.knownMiss(orderUID) {
+		return nil, false
+	}
+
+	v, err, _ := c.loads.Do(orderUID, func() (interface{}, error) {
+		order, err := c.loader.GetOrder(ctx, orderUID)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if e, ok := c.data[orderUID]; ok && e.order.Version >= order.Version {
+			return e.order, nil
+		}
+		c.insert(order)
+		return order, nil
+	})
+	if errors.Is(err, database.ErrOrderNotFound) {
+		c.rememberMiss(orderUID)
+		return nil, false
+	}
+	// Concurrent misses share the load of whichever caller came first. If
+	// that caller gave up, the others load again with their own context.
+	if ctx.Err() == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return c.load(ctx, orderUID)
+	}
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	if err != nil {
+		slog.Error("loading order from database failed", logging.KeyOrderUID, orderUID, logging.Err(err))
+		return nil, false
+	}
+	return v.(*models.Order), true
+}
+
+func (c *Cache) knownMiss(orderUID string) bool {
+	c.negativeMu.Lock()
+	defer c.negativeMu.Unlock()
+
+	expires, ok := c.negative[orderUID]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(c.negative, orderUID)
+		return false
+	}
+	return true
+}
+
+// SetNegativeTTL changes how long unknown orders are remembered. Misses
+// already remembered keep their expiry.
+func (c *Cache) SetNegativeTTL(ttl time.Duration) {
+	c.negativeMu.Lock()
+	defer c.negativeMu.Unlock()
+	c.negativeTTL = ttl
+}
+
+func (c *Cache) rememberMiss(orderUID string) {
+	c.negativeMu.Lock()
+	defer c.negativeMu.Unlock()
+
+	if c.negativeTTL <= 0 {
+		return
+	}
+
+	now := time.Now()
+	if len(c.negative) >= maxNegativeEntries {
+		for uid, expires := range c.negative {
+			if now.After(expires) {
+				delete(c.negative, uid)
+			}
+		}
+		if len(c.negative) >= maxNegativeEntries {
+			return
+		}
+	}
+	c.negative[orderUID] = now.Add(c.negativeTTL)
+}
+
+func (c *Cache) forgetMiss(orderUID string) {
+	c.negativeMu.Lock()
+	defer c.negativeMu.Unlock()
+	delete(c.negative, orderUID)
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-service/internal/database"
+	"order-service/internal/models"
+)
+
+type mockLoader struct {
+	calls   atomic.Int32
+	orders  map[string]*models.Order
+	err     error
+	release chan struct{}
+}
+
+func (m *mockLoader) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	m.calls.Add(1)
+	if m.release != nil {
+		select {
+		case <-m.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	order, ok := m.orders[orderUID]
+	if !ok {
+		return nil, database.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func TestCacheReadThrough(t *testing.T) {
+	loader := &mockLoader{orders: map[string]*models.Order{"DB_ONLY": {OrderUID: "DB_ONLY"}}}
+	cache := NewCache(WithLoader(loader, time.Minute))
+
+	order, exists := cache.Get("DB_ONLY")
+	if !exists || order.OrderUID != "DB_ONLY" {
+		t.Fatal("Order should be loaded from database on miss")
+	}
+
+	cache.Get("DB_ONLY")
+	if loader.calls.Load() != 1 {
+		t.Errorf("Loaded order should be cached, got %d loads", loader.calls.Load())
+	}
+}
+
+func TestCacheReadThroughCollapsesConcurrentMisses(t *testing.T) {
+	loader := &mockLoader{
+		orders:  map[string]*models.Order{"HOT": {OrderUID: "HOT"}},
+		release: make(chan struct{}),
+	}
+	cache := NewCache(WithLoader(loader, time.Minute))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, exists := cache.Get("HOT"); !exists {
+				t.Error("Order should be found")
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(loader.release)
+	wg.Wait()
+
+	if loader.calls.Load() != 1 {
+		t.Errorf("Expected 1 load for concurrent misses, got %d", loader.calls.Load())
+	}
+}
+
+func TestCacheNegativeCaching(t *testing.T) {
+	loader := &mockLoader{orders: map[string]*models.Order{}}
+	cache := NewCache(WithLoader(loader, 50*time.Millisecond))
+
+	cache.Get("UNKNOWN")
+	cache.Get("UNKNOWN")
+	if loader.calls.Load() != 1 {
+		t.Errorf("Unknown order should be negatively cached, got %d loads", loader.calls.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	cache.Get("UNKNOWN")
+	if loader.calls.Load() != 2 {
+		t.Errorf("Negative entry should expire, got %d loads", loader.calls.Load())
+	}
+}
+
+func TestCacheSetClearsNegativeEntry(t *testing.T) {
+	loader := &mockLoader{orders: map[string]*models.Order{}}
+	cache := NewCache(WithLoader(loader, time.Minute))
+
+	if _, exists := cache.Get("LATE"); exists {
+		t.Fatal("Order should not exist yet")
+	}
+
+	cache.Set(&models.Order{OrderUID: "LATE"})
+	if _, exists := cache.Get("LATE"); !exists {
+		t.Error("Order set after a miss should be found")
+	}
+}
+
+func TestCacheLoaderErrorNotCached(t *testing.T) {
+	loader := &mockLoader{err: errors.New("connection refused")}
+	cache := NewCache(WithLoader(loader, time.Minute))
+
+	cache.Get("ANY")
+	cache.Get("ANY")
+	if loader.calls.Load() != 2 {
+		t.Errorf("Loader errors must not be negatively cached, got %d loads", loader.calls.Load())
+	}
+}
+
+func TestCacheReadThroughHonoursContext(t *testing.T) {
+	loader := &mockLoader{
+		orders:  map[string]*models.Order{"SLOW": {OrderUID: "SLOW"}},
+		release: make(chan struct{}),
+	}
+	cache := NewCache(WithLoader(loader, time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	first := make(chan bool)
+	go func() {
+		_, exists := cache.GetContext(ctx, "SLOW")
+		first <- exists
+	}()
+	for loader.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// A second caller joins the first one's load, and must not fail just
+	// because the first caller gave up.
+	second := make(chan bool)
+	go func() {
+		_, exists := cache.GetContext(context.Background(), "SLOW")
+		second <- exists
+	}()
+
+	if <-first {
+		t.Error("A load cut short by the caller's context should be a miss")
+	}
+	for loader.calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(loader.release)
+	if !<-second {
+		t.Error("The second caller should load the order with its own context")
+	}
+}
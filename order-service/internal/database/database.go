@@ -1,13 +1,27 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"order-service/config"
 	"order-service/internal/models"
+	"order-service/internal/tracing"
+)
+
+const detailsBatchSize = 1000
+
+var (
+	ErrDuplicateOrder = errors.New("order version already stored")
+	ErrStaleVersion   = errors.New("stale order version")
+	ErrOrderNotFound  = errors.New("order not found")
 )
 
 type Database struct {
@@ -21,44 +35,101 @@ func NewDatabase(cfg *config.DatabaseConfig) (*Database, error) {
 		return nil, fmt.Errorf("error opening connection: %w", err)
 	}
 
+	conn.SetMaxOpenConns(cfg.MaxOpenConns)
+	conn.SetMaxIdleConns(cfg.MaxIdleConns)
+	conn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
 	if err := conn.Ping(); err != nil {
 		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
 
-	log.Println("Connected to PostgreSQL")
+	slog.Info("connected to PostgreSQL", "database", cfg)
 	return &Database{conn: conn}, nil
 }
 
-func (db *Database) SaveOrder(order *models.Order) error {
-	tx, err := db.conn.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+// SaveOrder inserts a new order or replaces an existing one when the
+// incoming version is newer. Delivery, payment and items are replaced in
+// the same transaction, which also queues an order.accepted outbox
+// message carrying the trace context of ctx. created reports whether the
+// order was inserted rather than updated.
+func (db *Database) SaveOrder(ctx context.Context, order *models.Order) (created bool, err error) {
+	ctx, span := tracing.Start(ctx, "SaveOrder", trace.WithAttributes(attribute.String("order.uid", order.OrderUID)))
+	defer func() { tracing.End(span, err) }()
 
-	var exists bool
-	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)", order.OrderUID).Scan(&exists)
+	tx, err := db.conn.BeginTx(ctx, nil)
 	if err != nil {
-		return err
-	}
-
-	if exists {
-		log.Printf("Order %s already exists, skipping", order.OrderUID)
-		return nil
+		return false, err
 	}
+	defer tx.Rollback()
 
-	_, err = tx.Exec(`
+	// Inserting first, rather than checking for the row, makes concurrent
+	// first saves of an order wait for each other: the loser conflicts
+	// and then compares versions against the committed row.
+	res, err := exec(ctx, tx, "INSERT orders", `
 		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, 
-			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (order_uid) DO NOTHING`,
 		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
 		order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, order.DateCreated, order.OofShard,
+		order.Version,
 	)
 	if err != nil {
-		return err
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
 	}
 
-	_, err = tx.Exec(`
+	if inserted == 0 {
+		var current int64
+		err = queryRow(ctx, tx, "SELECT orders",
+			"SELECT version FROM orders WHERE order_uid = $1 FOR UPDATE", []interface{}{order.OrderUID}, &current)
+		switch {
+		case err != nil:
+			return false, err
+		case order.Version == current:
+			return false, fmt.Errorf("%w: order %s version %d", ErrDuplicateOrder, order.OrderUID, current)
+		case order.Version < current:
+			return false, fmt.Errorf("%w: order %s has version %d, got %d", ErrStaleVersion, order.OrderUID, current, order.Version)
+		}
+
+		_, err = exec(ctx, tx, "UPDATE orders", `
+			UPDATE orders SET track_number = $2, entry = $3, locale = $4, internal_signature = $5,
+				customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9, date_created = $10,
+				oof_shard = $11, version = $12, updated_at = CURRENT_TIMESTAMP
+			WHERE order_uid = $1`,
+			order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+			order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, order.DateCreated, order.OofShard,
+			order.Version,
+		)
+		if err != nil {
+			return false, err
+		}
+
+		for _, table := range []string{"delivery", "payment", "items"} {
+			if _, err := exec(ctx, tx, "DELETE "+table, "DELETE FROM "+table+" WHERE order_uid = $1", order.OrderUID); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if err := insertOrderDetails(ctx, tx, order); err != nil {
+		return false, err
+	}
+	if err := insertOrderAccepted(ctx, tx, order); err != nil {
+		return false, err
+	}
+
+	_, commit := tracing.Start(ctx, "COMMIT")
+	err = tx.Commit()
+	tracing.End(commit, err)
+	return inserted == 1, err
+}
+
+func insertOrderDetails(ctx context.Context, tx *sql.Tx, order *models.Order) error {
+	_, err := exec(ctx, tx, "INSERT delivery", `
 		INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
 		order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
@@ -68,7 +139,7 @@ func (db *Database) SaveOrder(order *models.Order) error {
 		return err
 	}
 
-	_, err = tx.Exec(`
+	_, err = exec(ctx, tx, "INSERT payment", `
 		INSERT INTO payment (order_uid, transaction, request_id, currency, provider, 
 			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
@@ -81,7 +152,7 @@ func (db *Database) SaveOrder(order *models.Order) error {
 	}
 
 	for _, item := range order.Items {
-		_, err = tx.Exec(`
+		_, err = exec(ctx, tx, "INSERT items", `
 			INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, 
 				sale, size, total_price, nm_id, brand, status)
 			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
@@ -93,92 +164,198 @@ func (db *Database) SaveOrder(order *models.Order) error {
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
 
-func (db *Database) GetAllOrders() ([]*models.Order, error) {
-	rows, err := db.conn.Query(`
-		SELECT order_uid, track_number, entry, locale, internal_signature,
-			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+func (db *Database) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	orders, err := db.queryOrders(ctx, `
+		SELECT `+orderColumns+`
 		FROM orders
-		ORDER BY date_created DESC
-	`)
+		WHERE order_uid = $1
+	`, orderUID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	if len(orders) == 0 {
+		return nil, ErrOrderNotFound
+	}
+
+	if err := db.loadOrderDetails(ctx, orders); err != nil {
+		return nil, err
+	}
+	return orders[0], nil
+}
+
+// IterateOrders streams orders created at or after since, newest first,
+// in pages of batchSize using keyset pagination on (date_created, order_uid).
+// Iteration stops at the first error returned by fn or when ctx is done.
+func (db *Database) IterateOrders(ctx context.Context, since time.Time, batchSize int, fn func(batch []*models.Order) error) error {
+	var last *models.Order
+	for {
+		var (
+			batch []*models.Order
+			err   error
+		)
+		if last == nil {
+			batch, err = db.queryOrders(ctx, `
+				SELECT `+orderColumns+`
+				FROM orders
+				WHERE date_created >= $1
+				ORDER BY date_created DESC, order_uid DESC
+				LIMIT $2
+			`, since, batchSize)
+		} else {
+			batch, err = db.queryOrders(ctx, `
+				SELECT `+orderColumns+`
+				FROM orders
+				WHERE date_created >= $1 AND (date_created, order_uid) < ($2, $3)
+				ORDER BY date_created DESC, order_uid DESC
+				LIMIT $4
+			`, since, last.DateCreated, last.OrderUID, batchSize)
+		}
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
 
+		if err := db.loadOrderDetails(ctx, batch); err != nil {
+			return err
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+
+		if len(batch) < batchSize {
+			return nil
+		}
+		last = batch[len(batch)-1]
+	}
+}
+
+const orderColumns = `order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, version`
+
+func (db *Database) queryOrders(ctx context.Context, query string, args ...interface{}) ([]*models.Order, error) {
 	var orders []*models.Order
-	for rows.Next() {
+	err := queryRows(ctx, db.conn, "SELECT orders", query, args, func(rows *sql.Rows) error {
 		order := &models.Order{}
 		err := rows.Scan(
 			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
 			&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
-			&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard,
+			&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard, &order.Version,
 		)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		orders = append(orders, order)
+		return nil
+	})
+	return orders, err
+}
 
-		err = db.conn.QueryRow(`
-			SELECT name, phone, zip, city, address, region, email
-			FROM delivery WHERE order_uid = $1
-		`, order.OrderUID).Scan(
-			&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip,
-			&order.Delivery.City, &order.Delivery.Address, &order.Delivery.Region,
-			&order.Delivery.Email,
-		)
-		if err != nil && err != sql.ErrNoRows {
-			return nil, err
-		}
-
-		err = db.conn.QueryRow(`
-			SELECT transaction, request_id, currency, provider, amount,
-				payment_dt, bank, delivery_cost, goods_total, custom_fee
-			FROM payment WHERE order_uid = $1
-		`, order.OrderUID).Scan(
-			&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency,
-			&order.Payment.Provider, &order.Payment.Amount, &order.Payment.PaymentDt,
-			&order.Payment.Bank, &order.Payment.DeliveryCost, &order.Payment.GoodsTotal,
-			&order.Payment.CustomFee,
+// loadOrderDetails fills delivery, payment and items for orders with one
+// query per table for every detailsBatchSize orders.
+func (db *Database) loadOrderDetails(ctx context.Context, orders []*models.Order) error {
+	for start := 0; start < len(orders); start += detailsBatchSize {
+		end := start + detailsBatchSize
+		if end > len(orders) {
+			end = len(orders)
+		}
+
+		batch := orders[start:end]
+		byUID := make(map[string]*models.Order, len(batch))
+		uids := make([]string, 0, len(batch))
+		for _, order := range batch {
+			byUID[order.OrderUID] = order
+			uids = append(uids, order.OrderUID)
+		}
+
+		if err := db.loadDeliveries(ctx, uids, byUID); err != nil {
+			return err
+		}
+		if err := db.loadPayments(ctx, uids, byUID); err != nil {
+			return err
+		}
+		if err := db.loadItems(ctx, uids, byUID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *Database) loadDeliveries(ctx context.Context, uids []string, byUID map[string]*models.Order) error {
+	return queryRows(ctx, db.conn, "SELECT delivery", `
+		SELECT order_uid, name, phone, zip, city, address, region, email
+		FROM delivery WHERE order_uid = ANY($1)
+	`, []interface{}{pq.Array(uids)}, func(rows *sql.Rows) error {
+		var uid string
+		var d models.Delivery
+		err := rows.Scan(&uid, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email)
+		if err != nil {
+			return err
+		}
+		if order, ok := byUID[uid]; ok {
+			order.Delivery = d
+		}
+		return nil
+	})
+}
+
+func (db *Database) loadPayments(ctx context.Context, uids []string, byUID map[string]*models.Order) error {
+	return queryRows(ctx, db.conn, "SELECT payment", `
+		SELECT order_uid, transaction, request_id, currency, provider, amount,
+			payment_dt, bank, delivery_cost, goods_total, custom_fee
+		FROM payment WHERE order_uid = ANY($1)
+	`, []interface{}{pq.Array(uids)}, func(rows *sql.Rows) error {
+		var uid string
+		var p models.Payment
+		err := rows.Scan(
+			&uid, &p.Transaction, &p.RequestID, &p.Currency, &p.Provider, &p.Amount,
+			&p.PaymentDt, &p.Bank, &p.DeliveryCost, &p.GoodsTotal, &p.CustomFee,
 		)
-		if err != nil && err != sql.ErrNoRows {
-			return nil, err
+		if err != nil {
+			return err
+		}
+		if order, ok := byUID[uid]; ok {
+			order.Payment = p
 		}
+		return nil
+	})
+}
 
-		itemRows, err := db.conn.Query(`
-			SELECT chrt_id, track_number, price, rid, name, sale, size,
-				total_price, nm_id, brand, status
-			FROM items WHERE order_uid = $1
-		`, order.OrderUID)
+func (db *Database) loadItems(ctx context.Context, uids []string, byUID map[string]*models.Order) error {
+	return queryRows(ctx, db.conn, "SELECT items", `
+		SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status
+		FROM items WHERE order_uid = ANY($1)
+		ORDER BY order_uid, id
+	`, []interface{}{pq.Array(uids)}, func(rows *sql.Rows) error {
+		var uid string
+		item := models.Item{}
+		err := rows.Scan(
+			&uid, &item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid,
+			&item.Name, &item.Sale, &item.Size, &item.TotalPrice,
+			&item.NmID, &item.Brand, &item.Status,
+		)
 		if err != nil {
-			return nil, err
-		}
-
-		for itemRows.Next() {
-			item := models.Item{}
-			err := itemRows.Scan(
-				&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid,
-				&item.Name, &item.Sale, &item.Size, &item.TotalPrice,
-				&item.NmID, &item.Brand, &item.Status,
-			)
-			if err != nil {
-				itemRows.Close()
-				return nil, err
-			}
+			return err
+		}
+		if order, ok := byUID[uid]; ok {
 			order.Items = append(order.Items, item)
 		}
-		itemRows.Close()
-
-		orders = append(orders, order)
-	}
+		return nil
+	})
+}
 
-	return orders, nil
+func (db *Database) Ping(ctx context.Context) error {
+	return db.conn.PingContext(ctx)
 }
 
 func (db *Database) Close() error {
 	if db.conn != nil {
-		log.Println("Disconnecting from PostgreSQL")
+		slog.Info("disconnecting from PostgreSQL")
 		return db.conn.Close()
 	}
 	return nil
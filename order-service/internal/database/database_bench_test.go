@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// Benchmarks run against a real PostgreSQL with the order schema, e.g.
+// TEST_DATABASE_DSN="host=localhost user=orderservice password=... dbname=ordersdb sslmode=disable"
+// go test -bench=AllOrders ./internal/database
+const benchOrders = 500
+
+func openBenchDatabase(b *testing.B) *Database {
+	b.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		b.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal("Error opening database:", err)
+	}
+	db := &Database{conn: conn}
+	if _, err := db.MigrateUp(); err != nil {
+		b.Fatal("Error preparing schema:", err)
+	}
+	b.Cleanup(func() { conn.Close() })
+
+	for i := 0; i < benchOrders; i++ {
+		uid := fmt.Sprintf("BENCH_%04d", i)
+		order := &models.Order{
+			OrderUID:    uid,
+			TrackNumber: "BENCHTRACK",
+			Entry:       "WBIL",
+			DateCreated: time.Now(),
+			Delivery:    models.Delivery{Name: "Bench", Phone: "+9720000000", City: "City", Address: "Street"},
+			Payment:     models.Payment{Transaction: uid, Currency: "USD", Provider: "wbpay", Bank: "alpha"},
+			Items: []models.Item{
+				{TrackNumber: "BENCHTRACK", Rid: uid + "_1", Name: "Item 1", Brand: "Brand"},
+				{TrackNumber: "BENCHTRACK", Rid: uid + "_2", Name: "Item 2", Brand: "Brand"},
+			},
+		}
+		if _, err := db.SaveOrder(context.Background(), order); err != nil && !errors.Is(err, ErrDuplicateOrder) {
+			b.Fatal("Error seeding order:", err)
+		}
+	}
+	b.Cleanup(func() {
+		conn.Exec("DELETE FROM orders WHERE order_uid LIKE 'BENCH\\_%'")
+	})
+	return db
+}
+
+// getAllOrdersPerRow is the previous loader: one query for orders and
+// three more per order. Kept here as the benchmark baseline.
+func (db *Database) getAllOrdersPerRow() ([]*models.Order, error) {
+	rows, err := db.conn.Query(`
+		SELECT order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, version
+		FROM orders
+		ORDER BY date_created DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*models.Order
+	for rows.Next() {
+		order := &models.Order{}
+		err := rows.Scan(
+			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
+			&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
+			&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard, &order.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = db.conn.QueryRow(`
+			SELECT name, phone, zip, city, address, region, email
+			FROM delivery WHERE order_uid = $1
+		`, order.OrderUID).Scan(
+			&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip,
+			&order.Delivery.City, &order.Delivery.Address, &order.Delivery.Region,
+			&order.Delivery.Email,
+		)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		err = db.conn.QueryRow(`
+			SELECT transaction, request_id, currency, provider, amount,
+				payment_dt, bank, delivery_cost, goods_total, custom_fee
+			FROM payment WHERE order_uid = $1
+		`, order.OrderUID).Scan(
+			&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency,
+			&order.Payment.Provider, &order.Payment.Amount, &order.Payment.PaymentDt,
+			&order.Payment.Bank, &order.Payment.DeliveryCost, &order.Payment.GoodsTotal,
+			&order.Payment.CustomFee,
+		)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		itemRows, err := db.conn.Query(`
+			SELECT chrt_id, track_number, price, rid, name, sale, size,
+				total_price, nm_id, brand, status
+			FROM items WHERE order_uid = $1
+		`, order.OrderUID)
+		if err != nil {
+			return nil, err
+		}
+
+		for itemRows.Next() {
+			item := models.Item{}
+			err := itemRows.Scan(
+				&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid,
+				&item.Name, &item.Sale, &item.Size, &item.TotalPrice,
+				&item.NmID, &item.Brand, &item.Status,
+			)
+			if err != nil {
+				itemRows.Close()
+				return nil, err
+			}
+			order.Items = append(order.Items, item)
+		}
+		itemRows.Close()
+
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+func BenchmarkGetAllOrdersPerRow(b *testing.B) {
+	db := openBenchDatabase(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.getAllOrdersPerRow(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIterateAllOrders(b *testing.B) {
+	db := openBenchDatabase(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := db.IterateOrders(context.Background(), time.Time{}, benchOrders, func([]*models.Order) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
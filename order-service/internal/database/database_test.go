@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// TestSaveOrderConcurrentFirstSave runs against TEST_DATABASE_DSN, like
+// the benchmarks.
+func TestSaveOrderConcurrentFirstSave(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal("Error opening database:", err)
+	}
+	db := &Database{conn: conn}
+	defer db.Close()
+	if _, err := db.MigrateUp(); err != nil {
+		t.Fatal("Error migrating:", err)
+	}
+
+	const uid = "TEST_CONCURRENT_SAVE"
+	cleanup := func() { conn.Exec("DELETE FROM orders WHERE order_uid = $1", uid) }
+	cleanup()
+	defer cleanup()
+
+	const savers = 8
+	errs := make(chan error, savers)
+	var wg sync.WaitGroup
+	for i := 0; i < savers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			created, err := db.SaveOrder(context.Background(), &models.Order{
+				OrderUID:    uid,
+				DateCreated: time.Now(),
+				Items:       []models.Item{{Rid: uid + "_1"}},
+			})
+			if err == nil && !created {
+				t.Error("The first save of an order should report it as created")
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	saved := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			saved++
+		case !errors.Is(err, ErrDuplicateOrder):
+			t.Errorf("Concurrent saves should be duplicates, got %v", err)
+		}
+	}
+	if saved != 1 {
+		t.Errorf("Expected exactly one save to succeed, got %d", saved)
+	}
+
+	created, err := db.SaveOrder(context.Background(), &models.Order{
+		OrderUID:    uid,
+		DateCreated: time.Now(),
+		Items:       []models.Item{{Rid: uid + "_1"}},
+		Version:     1,
+	})
+	if err != nil || created {
+		t.Errorf("A newer version should update the order, got created=%t err=%v", created, err)
+	}
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"order-service/internal/models"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+func (db *Database) SaveDeadLetter(dl *models.DeadLetter) error {
+	return db.conn.QueryRow(`
+		INSERT INTO dead_letters (subject, sequence, redeliveries, payload, error)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		dl.Subject, int64(dl.Sequence), dl.Redeliveries, []byte(dl.Payload), dl.Error,
+	).Scan(&dl.ID, &dl.CreatedAt)
+}
+
+func (db *Database) ListDeadLetters(limit int) ([]*models.DeadLetter, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, subject, sequence, redeliveries, payload, error, created_at
+		FROM dead_letters
+		ORDER BY id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []*models.DeadLetter
+	for rows.Next() {
+		dl, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, dl)
+	}
+	return letters, rows.Err()
+}
+
+func (db *Database) GetDeadLetter(id int64) (*models.DeadLetter, error) {
+	row := db.conn.QueryRow(`
+		SELECT id, subject, sequence, redeliveries, payload, error, created_at
+		FROM dead_letters WHERE id = $1
+	`, id)
+	dl, err := scanDeadLetter(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrDeadLetterNotFound
+	}
+	return dl, err
+}
+
+func (db *Database) DeleteDeadLetter(id int64) error {
+	res, err := db.conn.Exec("DELETE FROM dead_letters WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+func (db *Database) PurgeDeadLetters() (int64, error) {
+	res, err := db.conn.Exec("DELETE FROM dead_letters")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeadLetter(row rowScanner) (*models.DeadLetter, error) {
+	dl := &models.DeadLetter{}
+	var sequence int64
+	var payload []byte
+	err := row.Scan(&dl.ID, &dl.Subject, &sequence, &dl.Redeliveries, &payload, &dl.Error, &dl.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	dl.Sequence = uint64(sequence)
+	dl.Payload = string(payload)
+	return dl, nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal("Error loading migrations:", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("No migrations embedded")
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Migration %s has version %d, expected %d", m.Name, m.Version, i+1)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("Migration %d_%s has an empty up or down script", m.Version, m.Name)
+		}
+	}
+}
+
+// TestMigrateUpIsIdempotent runs against TEST_DATABASE_DSN, like the
+// benchmarks. It only migrates up, so existing data is left alone.
+func TestMigrateUpIsIdempotent(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal("Error opening database:", err)
+	}
+	db := &Database{conn: conn}
+	defer db.Close()
+
+	if _, err := db.MigrateUp(); err != nil {
+		t.Fatal("Error migrating:", err)
+	}
+	if n, err := db.MigrateUp(); err != nil || n != 0 {
+		t.Fatalf("Second run should apply nothing, applied %d: %v", n, err)
+	}
+
+	status, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatal("Error reading status:", err)
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Errorf("Migration %d_%s not applied", s.Version, s.Name)
+		}
+	}
+}
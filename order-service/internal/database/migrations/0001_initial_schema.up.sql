@@ -0,0 +1,63 @@
+CREATE TABLE IF NOT EXISTS orders (
+    order_uid VARCHAR(255) PRIMARY KEY,
+    track_number VARCHAR(255) NOT NULL,
+    entry VARCHAR(50) NOT NULL,
+    locale VARCHAR(10),
+    internal_signature TEXT,
+    customer_id VARCHAR(255),
+    delivery_service VARCHAR(100),
+    shardkey VARCHAR(10),
+    sm_id INTEGER,
+    date_created TIMESTAMP NOT NULL,
+    oof_shard VARCHAR(10),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS delivery (
+    id SERIAL PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL UNIQUE REFERENCES orders (order_uid) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    phone VARCHAR(50) NOT NULL,
+    zip VARCHAR(20) NOT NULL,
+    city VARCHAR(100) NOT NULL,
+    address TEXT NOT NULL,
+    region VARCHAR(100) NOT NULL,
+    email VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS payment (
+    id SERIAL PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL UNIQUE REFERENCES orders (order_uid) ON DELETE CASCADE,
+    transaction VARCHAR(255) NOT NULL,
+    request_id VARCHAR(255),
+    currency VARCHAR(10) NOT NULL,
+    provider VARCHAR(100) NOT NULL,
+    amount INTEGER NOT NULL,
+    payment_dt BIGINT NOT NULL,
+    bank VARCHAR(100) NOT NULL,
+    delivery_cost INTEGER NOT NULL,
+    goods_total INTEGER NOT NULL,
+    custom_fee INTEGER DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS items (
+    id SERIAL PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL REFERENCES orders (order_uid) ON DELETE CASCADE,
+    chrt_id BIGINT NOT NULL,
+    track_number VARCHAR(255) NOT NULL,
+    price INTEGER NOT NULL,
+    rid VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    sale INTEGER NOT NULL,
+    size VARCHAR(50),
+    total_price INTEGER NOT NULL,
+    nm_id BIGINT NOT NULL,
+    brand VARCHAR(255) NOT NULL,
+    status INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders (customer_id);
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders (track_number);
+CREATE INDEX IF NOT EXISTS idx_delivery_order_uid ON delivery (order_uid);
+CREATE INDEX IF NOT EXISTS idx_payment_order_uid ON payment (order_uid);
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items (order_uid);
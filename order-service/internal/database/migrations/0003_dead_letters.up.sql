@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    subject VARCHAR(255) NOT NULL,
+    sequence BIGINT NOT NULL,
+    redeliveries INTEGER NOT NULL DEFAULT 0,
+    payload BYTEA NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
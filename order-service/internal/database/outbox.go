@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"order-service/internal/models"
+	"order-service/internal/tracing"
+)
+
+// insertOrderAccepted queues the event with the trace context of ctx as
+// its headers, so the relay can continue the trace when it publishes.
+func insertOrderAccepted(ctx context.Context, tx *sql.Tx, order *models.Order) error {
+	payload, err := json.Marshal(models.OrderAccepted{
+		OrderUID:    order.OrderUID,
+		Version:     order.Version,
+		TrackNumber: order.TrackNumber,
+		CustomerID:  order.CustomerID,
+		AcceptedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	var headers sql.NullString
+	if carrier := tracing.Inject(ctx); carrier != nil {
+		data, err := json.Marshal(carrier)
+		if err != nil {
+			return err
+		}
+		headers = sql.NullString{String: string(data), Valid: true}
+	}
+	_, err = exec(ctx, tx, "INSERT outbox",
+		"INSERT INTO outbox (event_type, payload, headers) VALUES ($1, $2, $3)",
+		models.EventOrderAccepted, payload, headers)
+	return err
+}
+
+// ProcessOutbox passes up to limit unpublished messages, oldest first, to
+// publish and marks those it accepted as published. Rows are locked with
+// SKIP LOCKED for the duration, so concurrent relays never share a
+// message, and a crash before commit leaves them unpublished to be sent
+// again. It stops at the first publish error and returns the number of
+// messages published.
+func (db *Database) ProcessOutbox(limit int, publish func(msg *models.OutboxMessage) error) (int, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, event_type, payload, headers, created_at
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var messages []*models.OutboxMessage
+	for rows.Next() {
+		msg := &models.OutboxMessage{}
+		var headers []byte
+		if err := rows.Scan(&msg.ID, &msg.EventType, &msg.Payload, &headers, &msg.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if len(headers) > 0 {
+			if err := json.Unmarshal(headers, &msg.Headers); err != nil {
+				rows.Close()
+				return 0, err
+			}
+		}
+		messages = append(messages, msg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var published []int64
+	var publishErr error
+	for _, msg := range messages {
+		if publishErr = publish(msg); publishErr != nil {
+			break
+		}
+		published = append(published, msg.ID)
+	}
+
+	if len(published) > 0 {
+		_, err := tx.Exec("UPDATE outbox SET published_at = CURRENT_TIMESTAMP WHERE id = ANY($1)", pq.Array(published))
+		if err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+	return len(published), publishErr
+}
+
+// DeletePublishedOutbox removes messages published before cutoff.
+func (db *Database) DeletePublishedOutbox(before time.Time) (int64, error) {
+	res, err := db.conn.Exec("DELETE FROM outbox WHERE published_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
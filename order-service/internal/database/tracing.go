@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"order-service/internal/tracing"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// startQuery begins a client span for one statement, named after the
+// operation and table, e.g. "INSERT orders".
+func startQuery(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.statement", strings.Join(strings.Fields(query), " ")),
+		),
+	)
+}
+
+func exec(ctx context.Context, q queryer, name, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, name, query)
+	res, err := q.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return res, err
+}
+
+// queryRow runs a single-row query and scans it into dest. It returns
+// sql.ErrNoRows like (*sql.Row).Scan, without marking the span failed.
+func queryRow(ctx context.Context, q queryer, name, query string, args []interface{}, dest ...interface{}) error {
+	ctx, span := startQuery(ctx, name, query)
+	err := q.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		span.End()
+		return err
+	}
+	tracing.End(span, err)
+	return err
+}
+
+// queryRows calls scan for every row, so the span covers reading the
+// results and not just sending the query.
+func queryRows(ctx context.Context, q queryer, name, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	ctx, span := startQuery(ctx, name, query)
+	err := func() error {
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	}()
+	tracing.End(span, err)
+	return err
+}
@@ -0,0 +1,73 @@
+package deadletter
+
+import (
+	"fmt"
+	"log/slog"
+
+	"order-service/internal/logging"
+	"order-service/internal/models"
+)
+
+type Store interface {
+	ListDeadLetters(limit int) ([]*models.DeadLetter, error)
+	GetDeadLetter(id int64) (*models.DeadLetter, error)
+	DeleteDeadLetter(id int64) error
+	PurgeDeadLetters() (int64, error)
+}
+
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+type Manager struct {
+	store     Store
+	publisher Publisher
+}
+
+func NewManager(store Store, publisher Publisher) *Manager {
+	return &Manager{
+		store:     store,
+		publisher: publisher,
+	}
+}
+
+func (m *Manager) List(limit int) ([]*models.DeadLetter, error) {
+	return m.store.ListDeadLetters(limit)
+}
+
+func (m *Manager) Get(id int64) (*models.DeadLetter, error) {
+	return m.store.GetDeadLetter(id)
+}
+
+func (m *Manager) Delete(id int64) error {
+	return m.store.DeleteDeadLetter(id)
+}
+
+func (m *Manager) Purge() (int64, error) {
+	n, err := m.store.PurgeDeadLetters()
+	if err != nil {
+		return 0, err
+	}
+	slog.Info("purged dead letters", "count", n)
+	return n, nil
+}
+
+// Replay republishes the stored payload to its original subject and
+// removes the entry once the broker has accepted it.
+func (m *Manager) Replay(id int64) error {
+	dl, err := m.store.GetDeadLetter(id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.publisher.Publish(dl.Subject, []byte(dl.Payload)); err != nil {
+		return fmt.Errorf("error republishing dead letter %d: %w", id, err)
+	}
+
+	if err := m.store.DeleteDeadLetter(id); err != nil {
+		return fmt.Errorf("dead letter %d replayed but not deleted: %w", id, err)
+	}
+
+	slog.Info("dead letter replayed", "dead_letter_id", id, logging.KeySubject, dl.Subject)
+	return nil
+}
@@ -0,0 +1,104 @@
+package deadletter
+
+import (
+	"errors"
+	"testing"
+
+	"order-service/internal/models"
+)
+
+var errNotFound = errors.New("not found")
+
+type mockStore struct {
+	data map[int64]*models.DeadLetter
+}
+
+func newMockStore(letters ...*models.DeadLetter) *mockStore {
+	s := &mockStore{data: make(map[int64]*models.DeadLetter)}
+	for _, dl := range letters {
+		s.data[dl.ID] = dl
+	}
+	return s
+}
+
+func (s *mockStore) ListDeadLetters(limit int) ([]*models.DeadLetter, error) {
+	var list []*models.DeadLetter
+	for _, dl := range s.data {
+		list = append(list, dl)
+	}
+	return list, nil
+}
+
+func (s *mockStore) GetDeadLetter(id int64) (*models.DeadLetter, error) {
+	dl, ok := s.data[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return dl, nil
+}
+
+func (s *mockStore) DeleteDeadLetter(id int64) error {
+	if _, ok := s.data[id]; !ok {
+		return errNotFound
+	}
+	delete(s.data, id)
+	return nil
+}
+
+func (s *mockStore) PurgeDeadLetters() (int64, error) {
+	n := int64(len(s.data))
+	s.data = make(map[int64]*models.DeadLetter)
+	return n, nil
+}
+
+type mockPublisher struct {
+	subject string
+	data    []byte
+	err     error
+}
+
+func (p *mockPublisher) Publish(subject string, data []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.subject = subject
+	p.data = data
+	return nil
+}
+
+func TestManagerReplay(t *testing.T) {
+	store := newMockStore(&models.DeadLetter{ID: 1, Subject: "orders", Payload: `{"order_uid":"1"}`})
+	publisher := &mockPublisher{}
+	manager := NewManager(store, publisher)
+
+	if err := manager.Replay(1); err != nil {
+		t.Fatal("Replay failed:", err)
+	}
+
+	if publisher.subject != "orders" || string(publisher.data) != `{"order_uid":"1"}` {
+		t.Errorf("Unexpected publish: subject=%s data=%s", publisher.subject, publisher.data)
+	}
+	if _, err := store.GetDeadLetter(1); err == nil {
+		t.Error("Replayed dead letter should be deleted")
+	}
+}
+
+func TestManagerReplayPublishError(t *testing.T) {
+	store := newMockStore(&models.DeadLetter{ID: 1, Subject: "orders"})
+	manager := NewManager(store, &mockPublisher{err: errors.New("broker down")})
+
+	if err := manager.Replay(1); err == nil {
+		t.Fatal("Expected replay error")
+	}
+	if _, err := store.GetDeadLetter(1); err != nil {
+		t.Error("Dead letter must be kept when republish fails")
+	}
+}
+
+func TestManagerReplayNotFound(t *testing.T) {
+	manager := NewManager(newMockStore(), &mockPublisher{})
+
+	if err := manager.Replay(42); !errors.Is(err, errNotFound) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
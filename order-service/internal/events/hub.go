@@ -0,0 +1,163 @@
+package events
+
+import (
+	"log/slog"
+	"sync"
+
+	"order-service/config"
+	"order-service/internal/models"
+)
+
+const (
+	Created = "created"
+	Updated = "updated"
+)
+
+type Event struct {
+	ID    uint64
+	Type  string
+	Order *models.Order
+}
+
+// Filter selects the orders a client is interested in. Empty fields
+// match anything.
+type Filter struct {
+	DeliveryService string `json:"delivery_service,omitempty"`
+	CustomerID      string `json:"customer_id,omitempty"`
+	TrackNumber     string `json:"track_number,omitempty"`
+}
+
+func (f Filter) Match(order *models.Order) bool {
+	return (f.DeliveryService == "" || order.DeliveryService == f.DeliveryService) &&
+		(f.CustomerID == "" || order.CustomerID == f.CustomerID) &&
+		(f.TrackNumber == "" || order.TrackNumber == f.TrackNumber)
+}
+
+// Client receives matching events published after it subscribed. C is
+// closed when the client unsubscribes or is dropped for falling behind.
+type Client struct {
+	C      <-chan Event
+	c      chan Event
+	filter Filter
+}
+
+// Hub fans order changes out to connected clients and keeps the
+// most recent events so that reconnecting clients can resume.
+type Hub struct {
+	mu      sync.Mutex
+	lastID  uint64
+	history []Event
+	next    int
+	full    bool
+	buffer  int
+	clients map[*Client]struct{}
+}
+
+func NewHub(cfg *config.EventsConfig) *Hub {
+	history := cfg.History
+	if history <= 0 {
+		history = 1
+	}
+	buffer := cfg.ClientBuffer
+	if buffer <= 0 {
+		buffer = 1
+	}
+	return &Hub{
+		history: make([]Event, history),
+		buffer:  buffer,
+		clients: make(map[*Client]struct{}),
+	}
+}
+
+// Publish records a stored order as the next event and delivers it to
+// every client whose filter matches. Its signature matches
+// cache.Observer. A client whose buffer is full is dropped instead of
+// blocking ingestion; it can reconnect and resume from its last event ID.
+func (h *Hub) Publish(order *models.Order, created bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event := Event{ID: h.lastID, Type: Updated, Order: order}
+	if created {
+		event.Type = Created
+	}
+	h.history[h.next] = event
+	h.next = (h.next + 1) % len(h.history)
+	if h.next == 0 {
+		h.full = true
+	}
+
+	for client := range h.clients {
+		if !client.filter.Match(order) {
+			continue
+		}
+		select {
+		case client.c <- event:
+		default:
+			slog.Warn("events client too slow, dropping it", "event_id", event.ID)
+			h.drop(client)
+		}
+	}
+}
+
+// Subscribe registers a client and returns the retained events newer than
+// lastID and matching filter for it to replay first. A lastID ahead of
+// the hub, e.g. from before a restart, replays everything retained.
+func (h *Hub) Subscribe(lastID uint64, filter Filter) (*Client, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := make(chan Event, h.buffer)
+	client := &Client{C: c, c: c, filter: filter}
+	h.clients[client] = struct{}{}
+
+	if lastID > h.lastID {
+		lastID = 0
+	}
+	return client, h.since(lastID, filter)
+}
+
+// SetFilter changes which events the client receives from now on.
+func (h *Hub) SetFilter(client *Client, filter Filter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client.filter = filter
+}
+
+func (h *Hub) Unsubscribe(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client]; ok {
+		h.drop(client)
+	}
+}
+
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+func (h *Hub) drop(client *Client) {
+	delete(h.clients, client)
+	close(client.c)
+}
+
+// since returns retained events with ID > lastID matching filter, oldest
+// first.
+func (h *Hub) since(lastID uint64, filter Filter) []Event {
+	start, n := 0, h.next
+	if h.full {
+		start, n = h.next, len(h.history)
+	}
+
+	var events []Event
+	for i := 0; i < n; i++ {
+		event := h.history[(start+i)%len(h.history)]
+		if event.ID > lastID && filter.Match(event.Order) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
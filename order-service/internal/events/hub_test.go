@@ -0,0 +1,100 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+
+	"order-service/config"
+	"order-service/internal/models"
+)
+
+func publish(h *Hub, n int) {
+	for i := 0; i < n; i++ {
+		h.Publish(&models.Order{OrderUID: fmt.Sprintf("ORDER_%d", i)}, true)
+	}
+}
+
+func TestHubFanOut(t *testing.T) {
+	hub := NewHub(&config.EventsConfig{History: 10, ClientBuffer: 10})
+	a, _ := hub.Subscribe(0, Filter{})
+	b, _ := hub.Subscribe(0, Filter{})
+
+	publish(hub, 2)
+
+	for _, client := range []*Client{a, b} {
+		for want := uint64(1); want <= 2; want++ {
+			if event := <-client.C; event.ID != want {
+				t.Errorf("Expected event %d, got %d", want, event.ID)
+			}
+		}
+	}
+}
+
+func TestHubResumeFromLastEventID(t *testing.T) {
+	hub := NewHub(&config.EventsConfig{History: 3, ClientBuffer: 10})
+	publish(hub, 5)
+
+	tests := []struct {
+		lastID uint64
+		want   []uint64
+	}{
+		{0, []uint64{3, 4, 5}},
+		{3, []uint64{4, 5}},
+		{5, nil},
+		{42, []uint64{3, 4, 5}},
+	}
+	for _, tt := range tests {
+		client, backlog := hub.Subscribe(tt.lastID, Filter{})
+		hub.Unsubscribe(client)
+
+		var got []uint64
+		for _, event := range backlog {
+			got = append(got, event.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Subscribe(%d) replayed %v, want %v", tt.lastID, got, tt.want)
+		}
+	}
+}
+
+func TestHubDropsSlowClient(t *testing.T) {
+	hub := NewHub(&config.EventsConfig{History: 10, ClientBuffer: 1})
+	slow, _ := hub.Subscribe(0, Filter{})
+
+	publish(hub, 2)
+
+	if hub.Clients() != 0 {
+		t.Fatalf("Slow client should be dropped, %d clients left", hub.Clients())
+	}
+	if event := <-slow.C; event.ID != 1 {
+		t.Errorf("Buffered event should still be readable, got %d", event.ID)
+	}
+	if _, ok := <-slow.C; ok {
+		t.Error("Dropped client's channel should be closed")
+	}
+	hub.Unsubscribe(slow)
+}
+
+func TestHubFilters(t *testing.T) {
+	hub := NewHub(&config.EventsConfig{History: 10, ClientBuffer: 10})
+	hub.Publish(&models.Order{OrderUID: "A", CustomerID: "alice"}, true)
+
+	client, backlog := hub.Subscribe(0, Filter{CustomerID: "bob"})
+	if len(backlog) != 0 {
+		t.Errorf("Backlog should be filtered, got %d events", len(backlog))
+	}
+
+	hub.Publish(&models.Order{OrderUID: "A", CustomerID: "alice", Version: 1}, false)
+	hub.Publish(&models.Order{OrderUID: "B", CustomerID: "bob", Version: 1}, false)
+
+	event := <-client.C
+	if event.Order.OrderUID != "B" || event.Type != Updated {
+		t.Errorf("Expected update of B, got %s %s", event.Type, event.Order.OrderUID)
+	}
+
+	hub.SetFilter(client, Filter{})
+	hub.Publish(&models.Order{OrderUID: "C"}, true)
+	if event := <-client.C; event.Order.OrderUID != "C" || event.Type != Created {
+		t.Errorf("Expected creation of C, got %s %s", event.Type, event.Order.OrderUID)
+	}
+}
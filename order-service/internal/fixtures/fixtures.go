@@ -0,0 +1,29 @@
+// Package fixtures holds test data shared by the tests of several packages.
+package fixtures
+
+import (
+	"time"
+
+	"order-service/internal/models"
+)
+
+// Order returns an order with uid that passes validation.
+func Order(uid string) *models.Order {
+	return &models.Order{
+		OrderUID:        uid,
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		DateCreated:     time.Now(),
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin", Address: "Ploshad Mira 15",
+		},
+		Payment: models.Payment{
+			Transaction: uid, Currency: "USD", Provider: "wbpay", Amount: 1817, PaymentDt: 1637907727, Bank: "alpha",
+		},
+		Items: []models.Item{{
+			TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "rid", Name: "Mascaras", TotalPrice: 317, Brand: "Vivienne Sabo",
+		}},
+	}
+}
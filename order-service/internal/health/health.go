@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/cache"
+)
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Result is the state of one dependency. Details is whatever helps an
+// operator tell why it is down, and is reported either way.
+type Result struct {
+	Status  Status `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type Check func(ctx context.Context) Result
+
+// Checker runs readiness checks concurrently, each bounded by the
+// configured timeout. The service is ready only if every check is up.
+type Checker struct {
+	timeout time.Duration
+	names   []string
+	checks  map[string]Check
+}
+
+func NewChecker(cfg *config.HealthConfig) *Checker {
+	return &Checker{
+		timeout: cfg.Timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	results := make([]Result, len(c.names))
+	var wg sync.WaitGroup
+	for i, name := range c.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.checks[name](ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(c.names))}
+	for i, name := range c.names {
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+		report.Checks[name] = results[i]
+	}
+	return report
+}
+
+func up(details any) Result {
+	return Result{Status: StatusUp, Details: details}
+}
+
+func down(err error, details any) Result {
+	return Result{Status: StatusDown, Error: err.Error(), Details: details}
+}
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Database is down when a ping does not succeed within the deadline.
+func Database(db Pinger) Check {
+	return func(ctx context.Context) Result {
+		start := time.Now()
+		err := db.Ping(ctx)
+		details := map[string]string{"latency": time.Since(start).String()}
+		if err != nil {
+			return down(err, details)
+		}
+		return up(details)
+	}
+}
+
+// Connection is implemented by the NATS transports. Kafka readers
+// connect per fetch and have no state to report.
+type Connection interface {
+	Connected() bool
+}
+
+var ErrDisconnected = errors.New("not connected")
+
+// Broker is down once the transport has reported losing its connection.
+func Broker(conn Connection) Check {
+	return func(context.Context) Result {
+		if !conn.Connected() {
+			return down(ErrDisconnected, nil)
+		}
+		return up(nil)
+	}
+}
+
+type Warmup interface {
+	WarmupStatus() cache.WarmupStatus
+}
+
+var ErrWarmingUp = errors.New("cache warm-up not finished")
+
+// Cache is down until RestoreFromDB has finished. A restore that failed
+// still counts as finished: misses then fall back to the database.
+func Cache(warmup Warmup) Check {
+	return func(context.Context) Result {
+		status := warmup.WarmupStatus()
+		if !status.Ready {
+			return down(ErrWarmingUp, status)
+		}
+		return up(status)
+	}
+}
+
+type MessageClock interface {
+	LastMessageAt() time.Time
+}
+
+type messageDetails struct {
+	LastMessageAt *time.Time `json:"last_message_at"`
+}
+
+// Messages reports when the subscriber last settled a message. It never
+// fails: a quiet queue is not a reason to take the service out of
+// rotation.
+func Messages(clock MessageClock) Check {
+	return func(context.Context) Result {
+		var details messageDetails
+		if at := clock.LastMessageAt(); !at.IsZero() {
+			details.LastMessageAt = &at
+		}
+		return up(details)
+	}
+}
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/cache"
+)
+
+type pinger struct {
+	err   error
+	block bool
+}
+
+func (p *pinger) Ping(ctx context.Context) error {
+	if p.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return p.err
+}
+
+type connection bool
+
+func (c connection) Connected() bool { return bool(c) }
+
+type warmup cache.WarmupStatus
+
+func (w warmup) WarmupStatus() cache.WarmupStatus { return cache.WarmupStatus(w) }
+
+type clock time.Time
+
+func (c clock) LastMessageAt() time.Time { return time.Time(c) }
+
+func TestCheckerAllUp(t *testing.T) {
+	c := NewChecker(&config.HealthConfig{Timeout: time.Second})
+	c.Add("database", Database(&pinger{}))
+	c.Add("broker", Broker(connection(true)))
+	c.Add("cache", Cache(warmup{Ready: true, Loaded: 3}))
+	c.Add("messages", Messages(clock{}))
+
+	report := c.Check(context.Background())
+	if report.Status != StatusUp {
+		t.Fatalf("Expected up, got %+v", report)
+	}
+	if len(report.Checks) != 4 {
+		t.Errorf("Expected a result per check, got %v", report.Checks)
+	}
+	if details := report.Checks["messages"].Details.(messageDetails); details.LastMessageAt != nil {
+		t.Errorf("No message yet, got %v", details.LastMessageAt)
+	}
+}
+
+func TestCheckerReportsFailures(t *testing.T) {
+	c := NewChecker(&config.HealthConfig{Timeout: 20 * time.Millisecond})
+	c.Add("database", Database(&pinger{block: true}))
+	c.Add("broker", Broker(connection(false)))
+	c.Add("cache", Cache(warmup{InProgress: true, Loaded: 10}))
+	c.Add("messages", Messages(clock(time.Now())))
+
+	report := c.Check(context.Background())
+	if report.Status != StatusDown {
+		t.Fatalf("Expected down, got %+v", report)
+	}
+	for _, name := range []string{"database", "broker", "cache"} {
+		if result := report.Checks[name]; result.Status != StatusDown || result.Error == "" {
+			t.Errorf("Expected %s down with an error, got %+v", name, result)
+		}
+	}
+	if report.Checks["cache"].Error != ErrWarmingUp.Error() {
+		t.Errorf("Expected warm-up error, got %q", report.Checks["cache"].Error)
+	}
+	if report.Checks["messages"].Status != StatusUp {
+		t.Error("Message recency must not fail readiness")
+	}
+}
+
+func TestDatabaseError(t *testing.T) {
+	result := Database(&pinger{err: errors.New("connection refused")})(context.Background())
+	if result.Status != StatusDown || result.Error != "connection refused" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
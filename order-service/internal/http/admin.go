@@ -0,0 +1,62 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"order-service/internal/logging"
+	"order-service/internal/reconcile"
+)
+
+type Reconciler interface {
+	Check(ctx context.Context, repair bool) (*reconcile.Report, error)
+	LastReport() *reconcile.Report
+}
+
+func (s *Server) setupAdminRoutes() {
+	s.router.HandleFunc("/api/admin/reconcile", s.handleLastReconcile).Methods("GET")
+	s.router.HandleFunc("/api/admin/reconcile", s.requireAdmin(s.handleReconcile)).Methods("POST")
+}
+
+func (s *Server) handleLastReconcile(w http.ResponseWriter, r *http.Request) {
+	report := s.reconciler.LastReport()
+	if report == nil {
+		http.Error(w, "No reconciliation has run yet", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleReconcile runs a check now; ?repair=true also fixes the cache.
+// A check scans the whole cache and database, so it needs the admin token
+// and is refused with 409 while another one runs.
+func (s *Server) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	repair := false
+	if value := r.URL.Query().Get("repair"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid repair", http.StatusBadRequest)
+			return
+		}
+		repair = b
+	}
+
+	report, err := s.reconciler.Check(r.Context(), repair)
+	if errors.Is(err, reconcile.ErrInProgress) {
+		http.Error(w, "Reconciliation already in progress", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, reconcile.ErrWarmingUp) {
+		writeJSON(w, http.StatusServiceUnavailable, report)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "reconciliation failed", logging.Err(err))
+		writeJSON(w, http.StatusInternalServerError, report)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
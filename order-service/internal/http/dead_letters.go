@@ -0,0 +1,112 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"order-service/internal/database"
+	"order-service/internal/logging"
+	"order-service/internal/models"
+)
+
+const (
+	defaultDeadLetterLimit = 100
+	maxDeadLetterLimit     = 1000
+)
+
+type DeadLetters interface {
+	List(limit int) ([]*models.DeadLetter, error)
+	Get(id int64) (*models.DeadLetter, error)
+	Delete(id int64) error
+	Replay(id int64) error
+	Purge() (int64, error)
+}
+
+func (s *Server) setupDeadLetterRoutes() {
+	s.router.HandleFunc("/api/dead-letters", s.handleListDeadLetters).Methods("GET")
+	s.router.HandleFunc("/api/dead-letters", s.requireAdmin(s.handlePurgeDeadLetters)).Methods("DELETE")
+	s.router.HandleFunc("/api/dead-letters/{id:[0-9]+}", s.handleGetDeadLetter).Methods("GET")
+	s.router.HandleFunc("/api/dead-letters/{id:[0-9]+}", s.requireAdmin(s.handleDeleteDeadLetter)).Methods("DELETE")
+	s.router.HandleFunc("/api/dead-letters/{id:[0-9]+}/replay", s.requireAdmin(s.handleReplayDeadLetter)).Methods("POST")
+}
+
+func (s *Server) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeadLetterLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxDeadLetterLimit)
+	}
+
+	letters, err := s.deadLetters.List(limit)
+	if err != nil {
+		s.deadLetterError(w, r, err)
+		return
+	}
+	if letters == nil {
+		letters = []*models.DeadLetter{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":        len(letters),
+		"dead_letters": letters,
+	})
+}
+
+func (s *Server) handleGetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	dl, err := s.deadLetters.Get(id)
+	if err != nil {
+		s.deadLetterError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dl)
+}
+
+func (s *Server) handleDeleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err := s.deadLetters.Delete(id); err != nil {
+		s.deadLetterError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err := s.deadLetters.Replay(id); err != nil {
+		s.deadLetterError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"replayed": id})
+}
+
+func (s *Server) handlePurgeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	n, err := s.deadLetters.Purge()
+	if err != nil {
+		s.deadLetterError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"purged": n})
+}
+
+func (s *Server) deadLetterError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, database.ErrDeadLetterNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	slog.ErrorContext(r.Context(), "dead letter operation failed", logging.Err(err))
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"order-service/internal/health"
+)
+
+type Readiness interface {
+	Check(ctx context.Context) health.Report
+}
+
+// handleHealthz is the liveness probe: answering at all is the check, so
+// a restart is only triggered when the process is stuck.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]health.Status{"status": health.StatusUp})
+}
+
+// handleReadyz is the readiness probe, failing while any dependency is
+// down or the server is shutting down.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := health.Report{Status: health.StatusUp, Checks: map[string]health.Result{}}
+	if s.readiness != nil {
+		report = s.readiness.Check(r.Context())
+	}
+	select {
+	case <-s.closing:
+		report.Status = health.StatusDown
+	default:
+	}
+
+	code := http.StatusOK
+	if report.Status != health.StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, report)
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencySweepInterval = time.Minute
+	// idempotencyEntryOverhead approximates the memory an entry takes
+	// besides its key and response: the struct, map slot and list element.
+	idempotencyEntryOverhead = 128
+)
+
+// errIdempotencyFull is returned by begin when requests still in progress
+// take up the whole byte budget.
+var errIdempotencyFull = errors.New("too many requests with an Idempotency-Key in progress")
+
+// idempotencyStore remembers responses by Idempotency-Key so that a
+// retried request gets the original response instead of running again.
+// A key reused with a different payload is rejected. Entries, in progress
+// or finished, are kept within maxBytes: stored responses are dropped
+// oldest first, and new keys are refused once requests in progress alone
+// fill the budget.
+type idempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	maxBytes  int64
+	entries   map[string]*idempotencyEntry
+	finished  *list.List
+	bytes     int64
+	lastSweep time.Time
+}
+
+type idempotencyEntry struct {
+	key     string
+	hash    [sha256.Size]byte
+	done    bool
+	status  int
+	body    []byte
+	expires time.Time
+	elem    *list.Element
+}
+
+func newIdempotencyStore(ttl time.Duration, maxBytes int64) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*idempotencyEntry),
+		finished: list.New(),
+	}
+}
+
+// begin claims key for a request with payload. If the key is already
+// known it returns the existing entry instead, which may still be in
+// progress.
+func (s *idempotencyStore) begin(key string, payload []byte) (*idempotencyEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if e, ok := s.entries[key]; ok && (!e.done || now.Before(e.expires)) {
+		return e, true, nil
+	} else if ok {
+		s.remove(e)
+	}
+	e := &idempotencyEntry{key: key, hash: sha256.Sum256(payload)}
+	if !s.makeRoom(e.size()) {
+		return nil, false, errIdempotencyFull
+	}
+	s.entries[key] = e
+	s.bytes += e.size()
+	return e, false, nil
+}
+
+func (s *idempotencyStore) matches(e *idempotencyEntry, payload []byte) bool {
+	return e.hash == sha256.Sum256(payload)
+}
+
+// finish stores the response for e. Server errors are not stored so
+// that the client can retry with the same key, and neither is a response
+// that alone exceeds maxBytes.
+func (s *idempotencyStore) finish(e *idempotencyEntry, status int, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries[e.key] != e {
+		return
+	}
+	size := int64(len(body))
+	if status >= 500 || (s.maxBytes > 0 && e.size()+size > s.maxBytes) || !s.makeRoom(size) {
+		s.remove(e)
+		return
+	}
+	e.done, e.status, e.body = true, status, body
+	e.expires = time.Now().Add(s.ttl)
+	e.elem = s.finished.PushBack(e)
+	s.bytes += size
+}
+
+// abandon releases e if it never finished, e.g. because the handler
+// panicked, so that a retry with the same key is not refused forever.
+func (s *idempotencyStore) abandon(e *idempotencyEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !e.done && s.entries[e.key] == e {
+		s.remove(e)
+	}
+}
+
+func (s *idempotencyStore) snapshot(e *idempotencyEntry) (done bool, status int, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return e.done, e.status, e.body
+}
+
+// makeRoom drops the oldest stored responses until size more bytes fit,
+// and reports whether they do. The caller must hold s.mu.
+func (s *idempotencyStore) makeRoom(size int64) bool {
+	for s.maxBytes > 0 && s.bytes+size > s.maxBytes {
+		front := s.finished.Front()
+		if front == nil {
+			return false
+		}
+		s.remove(front.Value.(*idempotencyEntry))
+	}
+	return true
+}
+
+// remove forgets e. The caller must hold s.mu.
+func (s *idempotencyStore) remove(e *idempotencyEntry) {
+	delete(s.entries, e.key)
+	s.bytes -= e.size()
+	if e.elem != nil {
+		s.finished.Remove(e.elem)
+		e.elem = nil
+	}
+}
+
+func (s *idempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < idempotencySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	// Entries finish in expiry order, since they share one TTL.
+	for front := s.finished.Front(); front != nil; front = s.finished.Front() {
+		e := front.Value.(*idempotencyEntry)
+		if now.Before(e.expires) {
+			break
+		}
+		s.remove(e)
+	}
+}
+
+func (e *idempotencyEntry) size() int64 {
+	return int64(idempotencyEntryOverhead + len(e.key) + len(e.body))
+}
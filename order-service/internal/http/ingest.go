@@ -0,0 +1,181 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"order-service/internal/database"
+	"order-service/internal/logging"
+	"order-service/internal/models"
+	"order-service/internal/service"
+)
+
+const (
+	maxIngestBody  = 10 << 20
+	maxIngestBatch = 1000
+)
+
+type Ingester interface {
+	Ingest(ctx context.Context, data []byte) (*models.Order, error)
+}
+
+type apiError struct {
+	Error      string             `json:"error"`
+	Message    string             `json:"message"`
+	Violations []models.Violation `json:"violations,omitempty"`
+}
+
+type batchResult struct {
+	Index    int       `json:"index"`
+	OrderUID string    `json:"order_uid,omitempty"`
+	Status   int       `json:"status"`
+	Error    *apiError `json:"error,omitempty"`
+}
+
+// handleIngestOrders accepts a single order object or an array of orders
+// and runs them through the same pipeline as broker messages. A single
+// order gets 201, 400, 409 or 422; a batch gets 201 if every order was
+// created and 207 with per-order results otherwise. A new
+// Idempotency-Key gets 429 while requests in progress fill the store.
+func (s *Server) handleIngestOrders(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, apiError{Error: "body_too_large", Message: err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "unreadable_body", Message: err.Error()})
+		return
+	}
+
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		status, resp := s.ingest(r.Context(), body)
+		writeJSON(w, status, resp)
+		return
+	}
+
+	entry, seen, err := s.idempotency.begin(key, body)
+	if err != nil {
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusTooManyRequests, apiError{Error: "too_many_requests", Message: err.Error()})
+		return
+	}
+	if seen {
+		if !s.idempotency.matches(entry, body) {
+			writeJSON(w, http.StatusUnprocessableEntity, apiError{
+				Error:   "idempotency_key_reused",
+				Message: "Idempotency-Key was already used with a different payload",
+			})
+			return
+		}
+		done, status, data := s.idempotency.snapshot(entry)
+		if !done {
+			writeJSON(w, http.StatusConflict, apiError{
+				Error:   "request_in_progress",
+				Message: "a request with this Idempotency-Key is still in progress",
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(status)
+		w.Write(data)
+		return
+	}
+
+	defer s.idempotency.abandon(entry)
+	status, resp := s.ingest(r.Context(), body)
+	if r.Context().Err() != nil {
+		// The client already got 503 from the request timeout, or is
+		// gone; replaying this response would contradict what it saw.
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+	s.idempotency.finish(entry, status, data)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func (s *Server) ingest(ctx context.Context, body []byte) (int, interface{}) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return s.ingestOne(ctx, trimmed)
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(trimmed, &batch); err != nil {
+		return ingestError(ctx, fmt.Errorf("%w: %v", service.ErrMalformed, err))
+	}
+	if len(batch) == 0 || len(batch) > maxIngestBatch {
+		return http.StatusUnprocessableEntity, apiError{
+			Error:   "invalid_batch",
+			Message: fmt.Sprintf("batch must contain between 1 and %d orders", maxIngestBatch),
+		}
+	}
+
+	results := make([]batchResult, len(batch))
+	created := 0
+	for i, raw := range batch {
+		status, resp := s.ingestOne(ctx, raw)
+		results[i] = batchResult{Index: i, Status: status}
+		switch resp := resp.(type) {
+		case *models.Order:
+			results[i].OrderUID = resp.OrderUID
+			created++
+		case apiError:
+			results[i].Error = &resp
+		}
+	}
+
+	status := http.StatusCreated
+	if created < len(batch) {
+		status = http.StatusMultiStatus
+	}
+	return status, map[string]interface{}{
+		"created": created,
+		"failed":  len(batch) - created,
+		"results": results,
+	}
+}
+
+func (s *Server) ingestOne(ctx context.Context, data []byte) (int, interface{}) {
+	order, err := s.ingester.Ingest(ctx, data)
+	if err != nil {
+		return ingestError(ctx, err)
+	}
+	return http.StatusCreated, order
+}
+
+func ingestError(ctx context.Context, err error) (int, interface{}) {
+	var invalid *models.ValidationError
+	switch {
+	case errors.Is(err, service.ErrMalformed):
+		return http.StatusBadRequest, apiError{Error: "malformed_order", Message: err.Error()}
+	case errors.As(err, &invalid):
+		return http.StatusUnprocessableEntity, apiError{
+			Error:      "invalid_order",
+			Message:    "order validation failed",
+			Violations: invalid.Violations,
+		}
+	case errors.Is(err, database.ErrDuplicateOrder):
+		return http.StatusConflict, apiError{Error: "duplicate_order", Message: err.Error()}
+	case errors.Is(err, database.ErrStaleVersion):
+		return http.StatusConflict, apiError{Error: "stale_version", Message: err.Error()}
+	default:
+		slog.ErrorContext(ctx, "order ingestion failed", logging.Err(err))
+		return http.StatusInternalServerError, apiError{Error: "internal_error", Message: "failed to save order"}
+	}
+}
@@ -0,0 +1,326 @@
+package http
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"order-service/internal/cache"
+	"order-service/internal/models"
+)
+
+const (
+	defaultOrderLimit = 50
+	maxOrderLimit     = 500
+	// maxOrderOffset bounds how deep a client can page: the heap holds
+	// offset+limit orders while the cache is read-locked. Deeper pages
+	// need narrower filters.
+	maxOrderOffset = 10000
+)
+
+const (
+	sortByDate     = "date_created"
+	sortByAmount   = "amount"
+	sortByCustomer = "customer_id"
+)
+
+// lookupParams are the /api/orders query parameters answered from the
+// cache's secondary indexes.
+var lookupParams = []string{
+	cache.IndexTrackNumber,
+	cache.IndexCustomerID,
+	cache.IndexTransaction,
+	cache.IndexRid,
+}
+
+// orderQuery is a parsed GET /api/orders request: filters, sort order and
+// the requested page.
+type orderQuery struct {
+	limit  int
+	offset int
+	sortBy string
+	desc   bool
+
+	from            time.Time
+	to              time.Time
+	deliveryService string
+	locale          string
+	currency        string
+	brand           string
+	customerID      string
+}
+
+func parseOrderQuery(values url.Values) (*orderQuery, error) {
+	q := &orderQuery{
+		limit:           defaultOrderLimit,
+		sortBy:          sortByDate,
+		desc:            true,
+		deliveryService: values.Get("delivery_service"),
+		locale:          values.Get("locale"),
+		currency:        values.Get("currency"),
+		brand:           values.Get("brand"),
+		customerID:      values.Get("customer_id"),
+	}
+
+	if value := values.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit %q", value)
+		}
+		q.limit = min(n, maxOrderLimit)
+	}
+	if value := values.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid offset %q", value)
+		}
+		if n > maxOrderOffset {
+			return nil, fmt.Errorf("invalid offset %q: must be at most %d", value, maxOrderOffset)
+		}
+		q.offset = n
+	}
+
+	if value := values.Get("sort"); value != "" {
+		switch value {
+		case sortByDate, sortByAmount, sortByCustomer:
+			q.sortBy = value
+		default:
+			return nil, fmt.Errorf("invalid sort %q: use %s, %s or %s", value, sortByDate, sortByAmount, sortByCustomer)
+		}
+	}
+	switch value := values.Get("order"); value {
+	case "", "desc":
+	case "asc":
+		q.desc = false
+	default:
+		return nil, fmt.Errorf("invalid order %q: use asc or desc", value)
+	}
+
+	var err error
+	if q.from, err = parseTimeParam(values, "from"); err != nil {
+		return nil, err
+	}
+	if q.to, err = parseTimeParam(values, "to"); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// parseTimeParam accepts RFC 3339 timestamps and plain dates.
+func parseTimeParam(values url.Values, name string) (time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %q: use RFC 3339 or YYYY-MM-DD", name, value)
+}
+
+// match applies the filters; from is inclusive, to is exclusive.
+func (q *orderQuery) match(order *models.Order) bool {
+	if !q.from.IsZero() && order.DateCreated.Before(q.from) {
+		return false
+	}
+	if !q.to.IsZero() && !order.DateCreated.Before(q.to) {
+		return false
+	}
+	if q.deliveryService != "" && order.DeliveryService != q.deliveryService {
+		return false
+	}
+	if q.locale != "" && order.Locale != q.locale {
+		return false
+	}
+	if q.currency != "" && order.Payment.Currency != q.currency {
+		return false
+	}
+	if q.customerID != "" && order.CustomerID != q.customerID {
+		return false
+	}
+	if q.brand != "" {
+		for _, item := range order.Items {
+			if item.Brand == q.brand {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// less reports whether a comes before b in the requested order. Ties are
+// broken by order UID so that pages are stable.
+func (q *orderQuery) less(a, b *models.Order) bool {
+	var c int
+	switch q.sortBy {
+	case sortByAmount:
+		c = cmp.Compare(a.Payment.Amount, b.Payment.Amount)
+	case sortByCustomer:
+		c = strings.Compare(a.CustomerID, b.CustomerID)
+	default:
+		c = a.DateCreated.Compare(b.DateCreated)
+	}
+	if c == 0 {
+		return a.OrderUID < b.OrderUID
+	}
+	if q.desc {
+		return c > 0
+	}
+	return c < 0
+}
+
+// orderPage keeps only the first offset+limit matching orders in a heap
+// with the last of them on top, so listing never holds the whole cache.
+type orderPage struct {
+	query  *orderQuery
+	orders []*models.Order
+	total  int
+}
+
+func (p *orderPage) Len() int           { return len(p.orders) }
+func (p *orderPage) Less(i, j int) bool { return p.query.less(p.orders[j], p.orders[i]) }
+func (p *orderPage) Swap(i, j int)      { p.orders[i], p.orders[j] = p.orders[j], p.orders[i] }
+
+func (p *orderPage) Push(x interface{}) {
+	p.orders = append(p.orders, x.(*models.Order))
+}
+
+func (p *orderPage) Pop() interface{} {
+	old := p.orders
+	n := len(old)
+	order := old[n-1]
+	old[n-1] = nil
+	p.orders = old[:n-1]
+	return order
+}
+
+func (p *orderPage) add(order *models.Order) bool {
+	if !p.query.match(order) {
+		return true
+	}
+	p.total++
+
+	keep := p.query.offset + p.query.limit
+	if len(p.orders) < keep {
+		heap.Push(p, order)
+	} else if p.query.less(order, p.orders[0]) {
+		p.orders[0] = order
+		heap.Fix(p, 0)
+	}
+	return true
+}
+
+// result returns the requested page in order.
+func (p *orderPage) result() []*models.Order {
+	sort.Slice(p.orders, func(i, j int) bool { return p.query.less(p.orders[i], p.orders[j]) })
+	if p.query.offset >= len(p.orders) {
+		return []*models.Order{}
+	}
+	return p.orders[p.query.offset:]
+}
+
+type orderList struct {
+	Count  int             `json:"count"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+	Orders []*models.Order `json:"orders"`
+	Next   string          `json:"next,omitempty"`
+	Prev   string          `json:"prev,omitempty"`
+}
+
+func (s *Server) handleGetAllOrders(w http.ResponseWriter, r *http.Request) {
+	q, err := parseOrderQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page := &orderPage{query: q}
+	if matches, ok := s.lookupOrders(r); ok {
+		for _, order := range matches {
+			page.add(order)
+		}
+	} else {
+		s.rangeOrders(r.Context(), page.add)
+	}
+	writeOrderPage(w, r, page)
+}
+
+func (s *Server) handleGetCustomerOrders(w http.ResponseWriter, r *http.Request) {
+	q, err := parseOrderQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page := &orderPage{query: q}
+	for _, order := range s.findBy(r.Context(), cache.IndexCustomerID, mux.Vars(r)["id"]) {
+		page.add(order)
+	}
+	writeOrderPage(w, r, page)
+}
+
+// lookupOrders answers index queries such as ?track_number=...; several
+// parameters are combined with AND. It reports false when the request
+// has none of them.
+func (s *Server) lookupOrders(r *http.Request) ([]*models.Order, bool) {
+	query := r.URL.Query()
+	var result []*models.Order
+	found := false
+	for _, param := range lookupParams {
+		if !query.Has(param) {
+			continue
+		}
+		matches := s.findBy(r.Context(), param, query.Get(param))
+		if !found {
+			result, found = matches, true
+			continue
+		}
+		uids := make(map[string]struct{}, len(matches))
+		for _, order := range matches {
+			uids[order.OrderUID] = struct{}{}
+		}
+		filtered := make([]*models.Order, 0, len(result))
+		for _, order := range result {
+			if _, ok := uids[order.OrderUID]; ok {
+				filtered = append(filtered, order)
+			}
+		}
+		result = filtered
+	}
+	return result, found
+}
+
+func writeOrderPage(w http.ResponseWriter, r *http.Request, page *orderPage) {
+	q := page.query
+	list := orderList{
+		Count:  page.total,
+		Limit:  q.limit,
+		Offset: q.offset,
+		Orders: page.result(),
+	}
+	if q.offset+q.limit < page.total && q.offset+q.limit <= maxOrderOffset {
+		list.Next = pageURL(r, q.offset+q.limit, q.limit)
+	}
+	if q.offset > 0 {
+		list.Prev = pageURL(r, max(q.offset-q.limit, 0), q.limit)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func pageURL(r *http.Request, offset, limit int) string {
+	values := r.URL.Query()
+	values.Set("offset", strconv.Itoa(offset))
+	values.Set("limit", strconv.Itoa(limit))
+	return r.URL.Path + "?" + values.Encode()
+}
@@ -1,43 +1,166 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gorilla/mux"
 	"order-service/config"
+	"order-service/internal/cache"
+	"order-service/internal/metrics"
 	"order-service/internal/models"
 )
 
 type Cache interface {
-	Get(orderUID string) (*models.Order, bool)
-	GetAll() map[string]*models.Order
+	GetContext(ctx context.Context, orderUID string) (*models.Order, bool)
+	Range(fn func(order *models.Order) bool)
+	FindBy(index, value string) []*models.Order
+}
+
+type Warmup interface {
+	WarmupStatus() cache.WarmupStatus
+}
+
+type CacheStats interface {
+	Stats() cache.Stats
 }
 
 type Server struct {
-	router *mux.Router
-	cache  Cache
-	port   string
+	router      *mux.Router
+	cache       Cache
+	deadLetters DeadLetters
+	warmup      Warmup
+	cacheStats  CacheStats
+	events      Events
+	ingester    Ingester
+	reconciler  Reconciler
+	readiness   Readiness
+	metrics     *metrics.Metrics
+	idempotency *idempotencyStore
+	port        string
+	adminToken  string
+
+	requestTimeout atomic.Int64
+
+	httpServer *http.Server
+	// closing is closed by Shutdown to end SSE and WebSocket streams,
+	// which http.Server.Shutdown would otherwise wait for or ignore.
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+type Option func(*Server)
+
+func WithDeadLetters(deadLetters DeadLetters) Option {
+	return func(s *Server) {
+		s.deadLetters = deadLetters
+	}
+}
+
+func WithWarmup(warmup Warmup) Option {
+	return func(s *Server) {
+		s.warmup = warmup
+	}
+}
+
+func WithCacheStats(stats CacheStats) Option {
+	return func(s *Server) {
+		s.cacheStats = stats
+	}
+}
+
+func WithEvents(events Events) Option {
+	return func(s *Server) {
+		s.events = events
+	}
+}
+
+func WithIngester(ingester Ingester) Option {
+	return func(s *Server) {
+		s.ingester = ingester
+	}
+}
+
+func WithReconciler(reconciler Reconciler) Option {
+	return func(s *Server) {
+		s.reconciler = reconciler
+	}
+}
+
+// WithReadiness makes /readyz report the given dependency checks.
+func WithReadiness(readiness Readiness) Option {
+	return func(s *Server) {
+		s.readiness = readiness
+	}
+}
+
+// WithMetrics serves /metrics and records the duration of every routed
+// request.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
 }
 
-func NewServer(cfg *config.HTTPConfig, cache Cache) *Server {
+func NewServer(cfg *config.HTTPConfig, cache Cache, opts ...Option) *Server {
 	server := &Server{
-		router: mux.NewRouter(),
-		cache:  cache,
-		port:   cfg.Port,
+		router:      mux.NewRouter(),
+		cache:       cache,
+		idempotency: newIdempotencyStore(cfg.IdempotencyTTL, int64(cfg.IdempotencyMaxBytes)),
+		port:        cfg.Port,
+		adminToken:  cfg.AdminToken,
+		closing:     make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	server.SetRequestTimeout(cfg.RequestTimeout)
 	server.setupRoutes()
+	server.httpServer = &http.Server{Addr: ":" + cfg.Port, Handler: server.router}
 	return server
 }
 
 func (s *Server) setupRoutes() {
+	s.router.Use(s.requestID, s.traceRequests)
+	if s.metrics != nil {
+		s.router.Use(s.instrument)
+		s.router.Handle("/metrics", s.metrics.Handler()).Methods("GET")
+	}
+	s.router.Use(s.limitDuration)
+	s.router.HandleFunc("/healthz", s.handleHealthz).Methods("GET")
+	s.router.HandleFunc("/readyz", s.handleReadyz).Methods("GET")
 	s.router.HandleFunc("/", s.handleIndex).Methods("GET")
+	if s.events != nil {
+		s.router.HandleFunc("/api/orders/stream", s.handleOrderStream).Methods("GET")
+		s.router.HandleFunc("/api/orders/ws", s.handleOrderSocket).Methods("GET")
+	}
 	s.router.HandleFunc("/api/orders/{id}", s.handleGetOrder).Methods("GET")
 	s.router.HandleFunc("/api/orders", s.handleGetAllOrders).Methods("GET")
+	if s.ingester != nil {
+		s.router.HandleFunc("/api/orders", s.handleIngestOrders).Methods("POST")
+	}
+	s.router.HandleFunc("/api/customers/{id}/orders", s.handleGetCustomerOrders).Methods("GET")
 	s.router.HandleFunc("/orders/{id}", s.handleOrderPage).Methods("GET")
+
+	if s.warmup != nil {
+		s.router.HandleFunc("/api/cache/status", s.handleCacheStatus).Methods("GET")
+	}
+	if s.cacheStats != nil {
+		s.router.HandleFunc("/api/cache/stats", s.handleCacheStats).Methods("GET")
+	}
+	if s.deadLetters != nil {
+		s.setupDeadLetterRoutes()
+	}
+	if s.reconciler != nil {
+		s.setupAdminRoutes()
+	}
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -339,10 +462,19 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
     </div>
 
     <script>
-        let allOrders = [];
-        let showingAll = false;
+        const PAGE_SIZE = 9;
+        const SORTS = {
+            'date-desc': 'sort=date_created&order=desc',
+            'date-asc': 'sort=date_created&order=asc',
+            'name-asc': 'sort=customer_id&order=asc',
+            'name-desc': 'sort=customer_id&order=desc',
+            'amount-desc': 'sort=amount&order=desc',
+            'amount-asc': 'sort=amount&order=asc'
+        };
+        let orders = [];
+        let total = 0;
+        let limit = PAGE_SIZE;
         let currentSort = 'date-desc';
-        const INITIAL_SHOW = 9;
 
         function searchOrder() {
             const id = document.getElementById('orderIdInput').value.trim();
@@ -361,44 +493,18 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
             });
             event.target.classList.add('active');
             
-            displayOrders();
-        }
-
-        function sortOrders(orders) {
-            const sorted = [...orders];
-            
-            switch(currentSort) {
-                case 'date-desc':
-                    sorted.sort((a, b) => new Date(b.date_created) - new Date(a.date_created));
-                    break;
-                case 'date-asc':
-                    sorted.sort((a, b) => new Date(a.date_created) - new Date(b.date_created));
-                    break;
-                case 'name-asc':
-                    sorted.sort((a, b) => (a.customer_id || '').localeCompare(b.customer_id || '', 'ru'));
-                    break;
-                case 'name-desc':
-                    sorted.sort((a, b) => (b.customer_id || '').localeCompare(a.customer_id || '', 'ru'));
-                    break;
-                case 'amount-desc':
-                    sorted.sort((a, b) => (b.payment?.amount || 0) - (a.payment?.amount || 0));
-                    break;
-                case 'amount-asc':
-                    sorted.sort((a, b) => (a.payment?.amount || 0) - (b.payment?.amount || 0));
-                    break;
-            }
-            
-            return sorted;
+            loadAllOrders();
         }
 
         async function loadAllOrders() {
             try {
-                const response = await fetch('/api/orders');
+                const response = await fetch('/api/orders?limit=' + limit + '&' + SORTS[currentSort]);
                 const data = await response.json();
                 
-                document.getElementById('cacheCount').textContent = data.count;
+                total = data.count;
+                document.getElementById('cacheCount').textContent = total;
                 
-                allOrders = data.orders || [];
+                orders = data.orders || [];
                 displayOrders();
                 
             } catch (err) {
@@ -413,7 +519,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
             
             grid.innerHTML = '';
             
-            if (allOrders.length === 0) {
+            if (orders.length === 0) {
                 emptyState.style.display = 'block';
                 showMoreBtn.classList.remove('visible');
                 return;
@@ -421,10 +527,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
             
             emptyState.style.display = 'none';
             
-            const sortedOrders = sortOrders(allOrders);
-            const ordersToShow = showingAll ? sortedOrders : sortedOrders.slice(0, INITIAL_SHOW);
-            
-            ordersToShow.forEach(order => {
+            orders.forEach(order => {
                 const card = document.createElement('div');
                 card.className = 'order-card';
                 card.onclick = () => window.location.href = '/orders/' + order.order_uid;
@@ -457,24 +560,46 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                 grid.appendChild(card);
             });
             
-            if (allOrders.length > INITIAL_SHOW) {
+            if (total > orders.length) {
+                showMoreBtn.classList.add('visible');
+                showMoreBtn.textContent = 'Показать ещё (' + (total - orders.length) + ' ещё)';
+            } else if (limit > PAGE_SIZE) {
                 showMoreBtn.classList.add('visible');
-                const remaining = allOrders.length - INITIAL_SHOW;
-                showMoreBtn.textContent = showingAll ? 
-                    'Скрыть' : 
-                    'Показать все (' + remaining + ' ещё)';
+                showMoreBtn.textContent = 'Скрыть';
             } else {
                 showMoreBtn.classList.remove('visible');
             }
         }
 
         function toggleOrders() {
-            showingAll = !showingAll;
-            displayOrders();
+            limit = total > orders.length ? limit + PAGE_SIZE : PAGE_SIZE;
+            loadAllOrders();
+        }
+
+        let reloadTimer = null;
+        function scheduleReload() {
+            clearTimeout(reloadTimer);
+            reloadTimer = setTimeout(loadAllOrders, 300);
+        }
+
+        function startPolling() {
+            setInterval(loadAllOrders, 5000);
         }
 
         loadAllOrders();
-        setInterval(loadAllOrders, 5000);
+        if (window.EventSource) {
+            const stream = new EventSource('/api/orders/stream');
+            stream.addEventListener('created', scheduleReload);
+            stream.addEventListener('updated', scheduleReload);
+            stream.addEventListener('open', scheduleReload);
+            stream.addEventListener('error', () => {
+                if (stream.readyState === EventSource.CLOSED) {
+                    startPolling();
+                }
+            });
+        } else {
+            startPolling();
+        }
     </script>
 </body>
 </html>`)
@@ -482,7 +607,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	order, exists := s.cache.Get(vars["id"])
+	order, exists := s.getOrder(r.Context(), vars["id"])
 	if !exists {
 		http.Error(w, "Order not found", http.StatusNotFound)
 		return
@@ -491,22 +616,22 @@ func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(order)
 }
 
-func (s *Server) handleGetAllOrders(w http.ResponseWriter, r *http.Request) {
-	orders := s.cache.GetAll()
-	list := make([]*models.Order, 0, len(orders))
-	for _, order := range orders {
-		list = append(list, order)
+func (s *Server) handleCacheStatus(w http.ResponseWriter, r *http.Request) {
+	status := s.warmup.WarmupStatus()
+	code := http.StatusOK
+	if !status.Ready {
+		code = http.StatusServiceUnavailable
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"count":  len(list),
-		"orders": list,
-	})
+	writeJSON(w, code, status)
+}
+
+func (s *Server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.cacheStats.Stats())
 }
 
 func (s *Server) handleOrderPage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	order, exists := s.cache.Get(vars["id"])
+	order, exists := s.getOrder(r.Context(), vars["id"])
 	if !exists {
 		http.Error(w, "Order not found", http.StatusNotFound)
 		return
@@ -769,7 +894,18 @@ h2 {
 	tmpl.Execute(w, order)
 }
 
+// Start serves until Shutdown is called, after which it returns nil.
 func (s *Server) Start() error {
-	log.Printf("HTTP server started on http://localhost:%s", s.port)
-	return http.ListenAndServe(":"+s.port, s.router)
+	slog.Info("HTTP server started", "port", s.port)
+	if err := s.httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting connections, ends event streams and waits for
+// in-flight requests to finish, or ctx to be done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.closing) })
+	return s.httpServer.Shutdown(ctx)
 }
@@ -1,13 +1,32 @@
 package http
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"order-service/config"
+	"order-service/internal/cache"
+	"order-service/internal/database"
+	"order-service/internal/events"
+	"order-service/internal/fixtures"
+	"order-service/internal/health"
+	"order-service/internal/metrics"
 	"order-service/internal/models"
+	"order-service/internal/reconcile"
+	"order-service/internal/service"
+	"order-service/internal/tracing/tracingtest"
 )
 
 type mockCache struct {
@@ -24,7 +43,11 @@ func (m *mockCache) Set(order *models.Order) {
 	m.data[order.OrderUID] = order
 }
 
-func (m *mockCache) Get(orderUID string) (*models.Order, bool) {
+func (m *mockCache) Store(order *models.Order, created bool) {
+	m.Set(order)
+}
+
+func (m *mockCache) GetContext(ctx context.Context, orderUID string) (*models.Order, bool) {
 	order, exists := m.data[orderUID]
 	return order, exists
 }
@@ -33,6 +56,25 @@ func (m *mockCache) GetAll() map[string]*models.Order {
 	return m.data
 }
 
+func (m *mockCache) Range(fn func(order *models.Order) bool) {
+	for _, order := range m.data {
+		if !fn(order) {
+			return
+		}
+	}
+}
+
+func (m *mockCache) FindBy(index, value string) []*models.Order {
+	var orders []*models.Order
+	for _, order := range m.data {
+		if (index == cache.IndexTrackNumber && order.TrackNumber == value) ||
+			(index == cache.IndexCustomerID && order.CustomerID == value) {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
 func TestServerGetOrder(t *testing.T) {
 	cache := newMockCache()
 	order := &models.Order{
@@ -110,6 +152,140 @@ func TestServerGetAllOrders(t *testing.T) {
 	}
 }
 
+func TestServerLookupOrders(t *testing.T) {
+	cache := newMockCache()
+	cache.Set(&models.Order{OrderUID: "A", TrackNumber: "TRACK1", CustomerID: "alice"})
+	cache.Set(&models.Order{OrderUID: "B", TrackNumber: "TRACK1", CustomerID: "bob"})
+	cache.Set(&models.Order{OrderUID: "C", TrackNumber: "TRACK2", CustomerID: "alice"})
+
+	server := NewServer(&config.HTTPConfig{Port: "8080"}, cache)
+
+	tests := []struct {
+		url   string
+		count int
+	}{
+		{"/api/orders?track_number=TRACK1", 2},
+		{"/api/orders?customer_id=alice", 2},
+		{"/api/orders?track_number=TRACK1&customer_id=alice", 1},
+		{"/api/orders?track_number=NONE", 0},
+		{"/api/customers/bob/orders", 1},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tt.url, w.Code)
+			continue
+		}
+
+		var response struct {
+			Count  int             `json:"count"`
+			Orders []*models.Order `json:"orders"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("%s: error decoding response: %v", tt.url, err)
+		}
+		if response.Count != tt.count || len(response.Orders) != tt.count {
+			t.Errorf("%s: expected %d orders, got count=%d orders=%d", tt.url, tt.count, response.Count, len(response.Orders))
+		}
+	}
+}
+
+func decodeOrderList(t *testing.T, w *httptest.ResponseRecorder) orderList {
+	t.Helper()
+	var list orderList
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatal("Error decoding response:", err)
+	}
+	return list
+}
+
+func TestServerOrdersPagination(t *testing.T) {
+	cache := newMockCache()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		cache.Set(&models.Order{
+			OrderUID:    fmt.Sprintf("ORDER_%d", i),
+			DateCreated: base.Add(time.Duration(i) * time.Hour),
+			Payment:     models.Payment{Amount: 100 - i*10},
+		})
+	}
+	server := NewServer(&config.HTTPConfig{Port: "8080"}, cache)
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/orders?limit=3", nil))
+	page := decodeOrderList(t, w)
+	if page.Count != 7 || len(page.Orders) != 3 || page.Prev != "" {
+		t.Fatalf("Unexpected first page: count=%d orders=%d prev=%q", page.Count, len(page.Orders), page.Prev)
+	}
+	if page.Orders[0].OrderUID != "ORDER_6" || page.Orders[2].OrderUID != "ORDER_4" {
+		t.Errorf("Expected newest orders first, got %s..%s", page.Orders[0].OrderUID, page.Orders[2].OrderUID)
+	}
+
+	var uids []string
+	for next := "/api/orders?limit=3&sort=amount&order=asc"; next != ""; {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", next, nil))
+		page := decodeOrderList(t, w)
+		for _, order := range page.Orders {
+			uids = append(uids, order.OrderUID)
+		}
+		next = page.Next
+	}
+	want := []string{"ORDER_6", "ORDER_5", "ORDER_4", "ORDER_3", "ORDER_2", "ORDER_1", "ORDER_0"}
+	if fmt.Sprint(uids) != fmt.Sprint(want) {
+		t.Errorf("Following next links by amount gave %v, want %v", uids, want)
+	}
+}
+
+func TestServerOrdersFilters(t *testing.T) {
+	cache := newMockCache()
+	cache.Set(&models.Order{
+		OrderUID: "A", DeliveryService: "meest", Locale: "en", DateCreated: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Payment: models.Payment{Currency: "USD"}, Items: []models.Item{{Brand: "Vivienne Sabo"}},
+	})
+	cache.Set(&models.Order{
+		OrderUID: "B", DeliveryService: "cdek", Locale: "ru", DateCreated: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Payment: models.Payment{Currency: "RUB"}, Items: []models.Item{{Brand: "Nike"}},
+	})
+	server := NewServer(&config.HTTPConfig{Port: "8080"}, cache)
+
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"delivery_service=meest", 1},
+		{"locale=ru", 1},
+		{"currency=USD&locale=ru", 0},
+		{"brand=Nike", 1},
+		{"from=2024-01-15", 1},
+		{"from=2024-01-01&to=2024-02-01T00:00:00Z", 1},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/orders?"+tt.query, nil))
+		if page := decodeOrderList(t, w); page.Count != tt.count {
+			t.Errorf("%s: expected %d orders, got %d", tt.query, tt.count, page.Count)
+		}
+	}
+
+	for _, query := range []string{
+		"limit=0", "offset=-1", "offset=10001", "offset=9223372036854775807", "sort=name", "order=up", "from=yesterday",
+	} {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/orders?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/orders?offset=10000", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("The deepest allowed offset should be accepted, got %d", w.Code)
+	}
+}
+
 func TestServerIndexPage(t *testing.T) {
 	cache := newMockCache()
 	cfg := &config.HTTPConfig{Port: "8080"}
@@ -128,3 +304,781 @@ func TestServerIndexPage(t *testing.T) {
 		t.Error("Expected Content-Type: text/html; charset=utf-8")
 	}
 }
+
+type mockDeadLetters struct {
+	data      map[int64]*models.DeadLetter
+	replayed  []int64
+	lastLimit int
+}
+
+func (m *mockDeadLetters) List(limit int) ([]*models.DeadLetter, error) {
+	m.lastLimit = limit
+	var list []*models.DeadLetter
+	for _, dl := range m.data {
+		list = append(list, dl)
+	}
+	return list, nil
+}
+
+func (m *mockDeadLetters) Get(id int64) (*models.DeadLetter, error) {
+	dl, ok := m.data[id]
+	if !ok {
+		return nil, database.ErrDeadLetterNotFound
+	}
+	return dl, nil
+}
+
+func (m *mockDeadLetters) Delete(id int64) error {
+	if _, ok := m.data[id]; !ok {
+		return database.ErrDeadLetterNotFound
+	}
+	delete(m.data, id)
+	return nil
+}
+
+func (m *mockDeadLetters) Replay(id int64) error {
+	if err := m.Delete(id); err != nil {
+		return err
+	}
+	m.replayed = append(m.replayed, id)
+	return nil
+}
+
+func (m *mockDeadLetters) Purge() (int64, error) {
+	n := int64(len(m.data))
+	m.data = make(map[int64]*models.DeadLetter)
+	return n, nil
+}
+
+func TestServerDeadLetters(t *testing.T) {
+	deadLetters := &mockDeadLetters{data: map[int64]*models.DeadLetter{
+		1: {ID: 1, Subject: "orders", Payload: "{", Error: "unexpected end of JSON input"},
+		2: {ID: 2, Subject: "orders", Payload: "{}", Error: "validation failed"},
+	}}
+	cfg := &config.HTTPConfig{Port: "8080", AdminToken: "secret"}
+	server := NewServer(cfg, newMockCache(), WithDeadLetters(deadLetters))
+
+	req := httptest.NewRequest("GET", "/api/dead-letters", nil)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/dead-letters?limit=1000000", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || deadLetters.lastLimit != maxDeadLetterLimit {
+		t.Errorf("Expected the limit clamped to %d, got status %d limit %d", maxDeadLetterLimit, w.Code, deadLetters.lastLimit)
+	}
+
+	req = httptest.NewRequest("GET", "/api/dead-letters/1", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	var dl models.DeadLetter
+	if err := json.NewDecoder(w.Body).Decode(&dl); err != nil {
+		t.Fatal("Error decoding response:", err)
+	}
+	if dl.Error != "unexpected end of JSON input" {
+		t.Errorf("Unexpected dead letter: %+v", dl)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/dead-letters", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || len(deadLetters.data) != 2 {
+		t.Errorf("Purge without the admin token should be refused, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/dead-letters/2/replay", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted || len(deadLetters.replayed) != 1 {
+		t.Errorf("Expected replay of 2, got status %d replayed %v", w.Code, deadLetters.replayed)
+	}
+
+	req = httptest.NewRequest("GET", "/api/dead-letters/2", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/dead-letters", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || len(deadLetters.data) != 0 {
+		t.Errorf("Expected purge, got status %d remaining %d", w.Code, len(deadLetters.data))
+	}
+}
+
+func TestServerAdminActionsDisabledWithoutToken(t *testing.T) {
+	deadLetters := &mockDeadLetters{data: map[int64]*models.DeadLetter{1: {ID: 1}}}
+	server := NewServer(&config.HTTPConfig{Port: "8080"}, newMockCache(),
+		WithDeadLetters(deadLetters), WithReconciler(&mockReconciler{}))
+
+	for _, route := range []struct{ method, path string }{
+		{"DELETE", "/api/dead-letters"},
+		{"DELETE", "/api/dead-letters/1"},
+		{"POST", "/api/dead-letters/1/replay"},
+		{"POST", "/api/admin/reconcile"},
+		{"POST", "/api/admin/reconcile?repair=true"},
+	} {
+		req := httptest.NewRequest(route.method, route.path, nil)
+		req.Header.Set("Authorization", "Bearer ")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s: expected 403 without a configured token, got %d", route.method, route.path, w.Code)
+		}
+	}
+	if len(deadLetters.data) != 1 {
+		t.Error("Dead letters must not change")
+	}
+}
+
+type mockWarmup struct {
+	status cache.WarmupStatus
+}
+
+func (m *mockWarmup) WarmupStatus() cache.WarmupStatus {
+	return m.status
+}
+
+func TestServerCacheStatus(t *testing.T) {
+	warmup := &mockWarmup{status: cache.WarmupStatus{InProgress: true, Loaded: 10}}
+	cfg := &config.HTTPConfig{Port: "8080"}
+	server := NewServer(cfg, newMockCache(), WithWarmup(warmup))
+
+	req := httptest.NewRequest("GET", "/api/cache/status", nil)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 during warm-up, got %d", w.Code)
+	}
+
+	warmup.status = cache.WarmupStatus{Ready: true, Loaded: 20}
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after warm-up, got %d", w.Code)
+	}
+}
+
+func readEvent(t *testing.T, reader *bufio.Reader) (id string, data string) {
+	t.Helper()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal("Error reading stream:", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && id != "":
+			return id, data
+		}
+	}
+}
+
+func TestServerOrderStream(t *testing.T) {
+	hub := events.NewHub(&config.EventsConfig{History: 10, ClientBuffer: 10})
+	hub.Publish(&models.Order{OrderUID: "MISSED"}, true)
+
+	server := NewServer(&config.HTTPConfig{Port: "8080"}, newMockCache(), WithEvents(hub), WithMetrics(metrics.New()))
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/api/orders/stream", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Error opening stream:", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	if id, data := readEvent(t, reader); id != "1" || !strings.Contains(data, "MISSED") {
+		t.Errorf("Expected replay of event 1, got id=%s data=%s", id, data)
+	}
+
+	hub.Publish(&models.Order{OrderUID: "LIVE"}, true)
+	if id, data := readEvent(t, reader); id != "2" || !strings.Contains(data, "LIVE") {
+		t.Errorf("Expected live event 2, got id=%s data=%s", id, data)
+	}
+}
+
+func readSocketMessage(t *testing.T, conn *websocket.Conn) socketMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg socketMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal("Error reading WebSocket message:", err)
+	}
+	return msg
+}
+
+func TestServerOrderSocket(t *testing.T) {
+	hub := events.NewHub(&config.EventsConfig{History: 10, ClientBuffer: 10})
+	server := NewServer(&config.HTTPConfig{Port: "8080"}, newMockCache(), WithEvents(hub), WithMetrics(metrics.New()))
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/orders/ws?customer_id=bob"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal("Error dialing WebSocket:", err)
+	}
+	defer conn.Close()
+
+	waitForClients(t, hub, 1)
+	hub.Publish(&models.Order{OrderUID: "ALICE_1", CustomerID: "alice"}, true)
+	hub.Publish(&models.Order{OrderUID: "BOB_1", CustomerID: "bob"}, true)
+	hub.Publish(&models.Order{OrderUID: "BOB_1", CustomerID: "bob", Version: 1}, false)
+
+	if msg := readSocketMessage(t, conn); msg.Type != events.Created || msg.Order.OrderUID != "BOB_1" {
+		t.Errorf("Expected creation of BOB_1, got %s %+v", msg.Type, msg.Order)
+	}
+	if msg := readSocketMessage(t, conn); msg.Type != events.Updated || msg.Order.Version != 1 {
+		t.Errorf("Expected update of BOB_1, got %s %+v", msg.Type, msg.Order)
+	}
+
+	conn.WriteJSON(map[string]string{"action": "subscribe", "track_number": "TRACK2"})
+	if msg := readSocketMessage(t, conn); msg.Type != "subscribed" || msg.Filter.TrackNumber != "TRACK2" {
+		t.Fatalf("Expected subscribe confirmation, got %+v", msg)
+	}
+
+	hub.Publish(&models.Order{OrderUID: "BOB_2", CustomerID: "bob", TrackNumber: "TRACK1"}, true)
+	hub.Publish(&models.Order{OrderUID: "ALICE_2", CustomerID: "alice", TrackNumber: "TRACK2"}, true)
+	if msg := readSocketMessage(t, conn); msg.Order.OrderUID != "ALICE_2" {
+		t.Errorf("Expected ALICE_2 after changing filter, got %+v", msg.Order)
+	}
+
+	conn.Close()
+	waitForClients(t, hub, 0)
+}
+
+func waitForClients(t *testing.T, hub *events.Hub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Clients() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d hub clients, got %d", n, hub.Clients())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type mockOrderStore struct {
+	versions map[string]int64
+	saves    int
+	panics   bool
+	delay    time.Duration
+}
+
+func (m *mockOrderStore) SaveOrder(ctx context.Context, order *models.Order) (bool, error) {
+	if m.panics {
+		panic("save failed")
+	}
+	time.Sleep(m.delay)
+	m.saves++
+	version, ok := m.versions[order.OrderUID]
+	if ok && version >= order.Version {
+		return false, database.ErrDuplicateOrder
+	}
+	m.versions[order.OrderUID] = order.Version
+	return !ok, nil
+}
+
+func newIngestServer() (*Server, *mockCache, *mockOrderStore) {
+	cache := newMockCache()
+	store := &mockOrderStore{versions: make(map[string]int64)}
+	cfg := &config.HTTPConfig{Port: "8080", IdempotencyTTL: time.Hour}
+	return NewServer(cfg, cache, WithIngester(service.NewService(cache, store))), cache, store
+}
+
+func postOrders(server *Server, body interface{}, key string) *httptest.ResponseRecorder {
+	data, ok := body.([]byte)
+	if !ok {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest("POST", "/api/orders", bytes.NewReader(data))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestServerIngestOrder(t *testing.T) {
+	server, cache, _ := newIngestServer()
+
+	invalid := fixtures.Order("INVALID")
+	invalid.Payment.Currency = "dollars"
+
+	tests := []struct {
+		name   string
+		body   interface{}
+		status int
+		code   string
+	}{
+		{"created", fixtures.Order("A"), http.StatusCreated, ""},
+		{"duplicate", fixtures.Order("A"), http.StatusConflict, "duplicate_order"},
+		{"invalid", invalid, http.StatusUnprocessableEntity, "invalid_order"},
+		{"malformed", []byte("{not json"), http.StatusBadRequest, "malformed_order"},
+	}
+	for _, tt := range tests {
+		w := postOrders(server, tt.body, "")
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.status, w.Code, w.Body)
+			continue
+		}
+		if tt.code == "" {
+			continue
+		}
+		var resp apiError
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp.Error != tt.code {
+			t.Errorf("%s: expected error %q, got %+v", tt.name, tt.code, resp)
+		}
+		if tt.name == "invalid" && (len(resp.Violations) != 1 || resp.Violations[0].Field != "payment.currency") {
+			t.Errorf("Expected payment.currency violation, got %+v", resp.Violations)
+		}
+	}
+
+	if _, ok := cache.data["A"]; !ok {
+		t.Error("Ingested order should be cached")
+	}
+}
+
+func TestServerIngestBatch(t *testing.T) {
+	server, _, store := newIngestServer()
+
+	invalid := fixtures.Order("B")
+	invalid.Items = nil
+
+	w := postOrders(server, []*models.Order{fixtures.Order("A"), invalid}, "")
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("Expected status 207, got %d", w.Code)
+	}
+
+	var resp struct {
+		Created int           `json:"created"`
+		Failed  int           `json:"failed"`
+		Results []batchResult `json:"results"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Created != 1 || resp.Failed != 1 || store.saves != 1 {
+		t.Errorf("Expected 1 created and 1 failed, got %+v with %d saves", resp, store.saves)
+	}
+	if resp.Results[1].Status != http.StatusUnprocessableEntity || resp.Results[1].Error == nil {
+		t.Errorf("Expected 422 for the invalid order, got %+v", resp.Results[1])
+	}
+
+	if w := postOrders(server, []*models.Order{fixtures.Order("C")}, ""); w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 for a fully created batch, got %d", w.Code)
+	}
+}
+
+func TestServerIngestIdempotencyKey(t *testing.T) {
+	server, _, store := newIngestServer()
+	order := fixtures.Order("A")
+
+	first := postOrders(server, order, "key-1")
+	second := postOrders(server, order, "key-1")
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("Expected both responses to be 201, got %d and %d", first.Code, second.Code)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" || second.Body.String() != first.Body.String() {
+		t.Error("Retry should replay the original response")
+	}
+	if store.saves != 1 {
+		t.Errorf("Retry must not save again, got %d saves", store.saves)
+	}
+
+	if w := postOrders(server, fixtures.Order("B"), "key-1"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Reusing a key with another payload should be rejected, got %d", w.Code)
+	}
+}
+
+func TestServerIngestReleasesKeyAfterPanic(t *testing.T) {
+	server, _, store := newIngestServer()
+	order := fixtures.Order("A")
+
+	store.panics = true
+	func() {
+		defer func() { recover() }()
+		postOrders(server, order, "key-1")
+	}()
+
+	store.panics = false
+	if w := postOrders(server, order, "key-1"); w.Code != http.StatusCreated {
+		t.Errorf("Retry after a panic should run again, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyStoreBoundsBytes(t *testing.T) {
+	entry := int64(idempotencyEntryOverhead + 1 + 10)
+	store := newIdempotencyStore(time.Hour, 2*entry+5)
+	for _, key := range []string{"a", "b"} {
+		e, _, _ := store.begin(key, nil)
+		store.finish(e, http.StatusCreated, make([]byte, 10))
+	}
+	e, _, _ := store.begin("c", nil)
+	store.finish(e, http.StatusCreated, make([]byte, 10))
+
+	if _, seen, _ := store.begin("a", nil); seen {
+		t.Error("The oldest response should be dropped to stay within the limit")
+	}
+	if _, seen, _ := store.begin("c", nil); !seen {
+		t.Error("The newest response should be kept")
+	}
+	if store.bytes > 2*entry+5 {
+		t.Errorf("Expected at most %d bytes, got %d", 2*entry+5, store.bytes)
+	}
+
+	e, _, _ = store.begin("huge", nil)
+	store.finish(e, http.StatusCreated, make([]byte, 1000))
+	if _, seen, _ := store.begin("huge", nil); seen {
+		t.Error("A response over the limit should not be stored")
+	}
+}
+
+func TestIdempotencyStoreBoundsRequestsInProgress(t *testing.T) {
+	store := newIdempotencyStore(time.Hour, 3*(idempotencyEntryOverhead+1))
+	var pending []*idempotencyEntry
+	for _, key := range []string{"a", "b", "c"} {
+		e, _, err := store.begin(key, nil)
+		if err != nil {
+			t.Fatalf("Key %s should fit, got %v", key, err)
+		}
+		pending = append(pending, e)
+	}
+	if _, _, err := store.begin("d", nil); !errors.Is(err, errIdempotencyFull) {
+		t.Fatalf("Expected errIdempotencyFull once requests in progress fill the store, got %v", err)
+	}
+
+	store.abandon(pending[0])
+	if _, _, err := store.begin("d", nil); err != nil {
+		t.Errorf("A released key should make room, got %v", err)
+	}
+}
+
+func TestServerIngestRejectsKeysWhenIdempotencyStoreFull(t *testing.T) {
+	server, _, _ := newIngestServer()
+	server.idempotency = newIdempotencyStore(time.Hour, int64(idempotencyEntryOverhead+len("key-1")))
+	if _, _, err := server.idempotency.begin("key-1", nil); err != nil {
+		t.Fatal("Error claiming key:", err)
+	}
+
+	w := postOrders(server, fixtures.Order("A"), "key-2")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After, got %d", w.Code)
+	}
+}
+
+func TestServerIngestDoesNotReplayTimedOutResponse(t *testing.T) {
+	server, _, store := newIngestServer()
+	store.delay = 50 * time.Millisecond
+	server.SetRequestTimeout(10 * time.Millisecond)
+	order := fixtures.Order("A")
+
+	if w := postOrders(server, order, "key-1"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 for a request over the timeout, got %d", w.Code)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		server.idempotency.mu.Lock()
+		_, claimed := server.idempotency.entries["key-1"]
+		server.idempotency.mu.Unlock()
+		if !claimed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The timed-out request should release its key")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	server.SetRequestTimeout(0)
+	w := postOrders(server, order, "key-1")
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("A retry must not replay a response the client never got, got %d", w.Code)
+	}
+}
+
+type mockReconciler struct {
+	last    *reconcile.Report
+	repairs []bool
+	err     error
+}
+
+func (m *mockReconciler) Check(ctx context.Context, repair bool) (*reconcile.Report, error) {
+	if m.err != nil {
+		return &reconcile.Report{Error: m.err.Error()}, m.err
+	}
+	m.repairs = append(m.repairs, repair)
+	m.last = &reconcile.Report{Extra: []string{"EXTRA"}}
+	if repair {
+		m.last.Repaired = 1
+	}
+	return m.last, nil
+}
+
+func (m *mockReconciler) LastReport() *reconcile.Report {
+	return m.last
+}
+
+func TestServerReconcile(t *testing.T) {
+	reconciler := &mockReconciler{}
+	server := NewServer(&config.HTTPConfig{Port: "8080", AdminToken: "secret"}, newMockCache(),
+		WithReconciler(reconciler))
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/reconcile", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 before the first check, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/api/admin/reconcile", "/api/admin/reconcile?repair=true"} {
+		w = httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		if w.Code != http.StatusUnauthorized || len(reconciler.repairs) != 0 {
+			t.Errorf("POST %s without the admin token should be refused, got %d", path, w.Code)
+		}
+	}
+
+	reconcileRequest := func(path string) *http.Request {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		return req
+	}
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, reconcileRequest("/api/admin/reconcile?repair=true"))
+	var report reconcile.Report
+	json.NewDecoder(w.Body).Decode(&report)
+	if w.Code != http.StatusOK || report.Repaired != 1 || len(reconciler.repairs) != 1 || !reconciler.repairs[0] {
+		t.Errorf("Expected a repairing check, got %d %+v", w.Code, report)
+	}
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/reconcile", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the last report, got %d", w.Code)
+	}
+
+	reconciler.err = reconcile.ErrWarmingUp
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, reconcileRequest("/api/admin/reconcile"))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 during cache warm-up, got %d", w.Code)
+	}
+
+	reconciler.err = reconcile.ErrInProgress
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, reconcileRequest("/api/admin/reconcile"))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 while another check runs, got %d", w.Code)
+	}
+}
+
+func TestServerMetrics(t *testing.T) {
+	m := metrics.New()
+	mockCache := newMockCache()
+	mockCache.Set(&models.Order{OrderUID: "METRICS_ORDER"})
+	server := NewServer(&config.HTTPConfig{Port: "8080"}, mockCache, WithMetrics(m))
+
+	for _, uid := range []string{"METRICS_ORDER", "MISSING_1", "MISSING_2"} {
+		server.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/orders/"+uid, nil))
+	}
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from /metrics, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, series := range []string{
+		`order_service_http_request_duration_seconds_count{method="GET",route="/api/orders/{id}",status="200"} 1`,
+		`order_service_http_request_duration_seconds_count{method="GET",route="/api/orders/{id}",status="404"} 2`,
+	} {
+		if !strings.Contains(body, series) {
+			t.Errorf("Expected %s in /metrics output", series)
+		}
+	}
+	if strings.Contains(body, "MISSING_1") {
+		t.Error("Route label must be the template, not the path")
+	}
+}
+
+func TestServerTracing(t *testing.T) {
+	exporter := tracingtest.InMemory()
+	mockCache := newMockCache()
+	mockCache.Set(&models.Order{OrderUID: "TRACED_ORDER"})
+	server := NewServer(&config.HTTPConfig{Port: "8080"}, mockCache)
+
+	req := httptest.NewRequest("GET", "/api/orders/TRACED_ORDER", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	server.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected cache.Get and request spans, got %d", len(spans))
+	}
+	get, request := spans[0], spans[1]
+	if request.Name != "GET /api/orders/{id}" || request.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Request span %q should continue the incoming trace, parent %s", request.Name, request.Parent.SpanID())
+	}
+	if get.Name != "cache.Get" || get.Parent.SpanID() != request.SpanContext.SpanID() {
+		t.Errorf("Expected cache.Get under the request span, got %q", get.Name)
+	}
+}
+
+func TestServerRequestID(t *testing.T) {
+	server := NewServer(&config.HTTPConfig{Port: "8080"}, newMockCache())
+
+	req := httptest.NewRequest("GET", "/api/orders/MISSING", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Request-ID"); got != "req-123" {
+		t.Errorf("Expected caller's request ID to be echoed, got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/api/orders/MISSING", nil)
+	req.Header.Set("X-Request-ID", "bad id\n")
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Request-ID"); len(got) != 32 {
+		t.Errorf("Expected a generated request ID in place of an invalid one, got %q", got)
+	}
+}
+
+func TestServerShutdownEndsStreams(t *testing.T) {
+	hub := events.NewHub(&config.EventsConfig{History: 10, ClientBuffer: 10})
+	server := NewServer(&config.HTTPConfig{Port: "0"}, newMockCache(), WithEvents(hub))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.httpServer.Serve(ln)
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/api/orders/stream")
+	if err != nil {
+		t.Fatal("Error opening stream:", err)
+	}
+	defer resp.Body.Close()
+	waitForClients(t, hub, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown should not wait for open streams to time out: %v", err)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("Expected the stream to end cleanly, got %v", err)
+	}
+}
+
+type mockReadiness struct {
+	report health.Report
+}
+
+func (m *mockReadiness) Check(ctx context.Context) health.Report {
+	return m.report
+}
+
+func TestServerHealthProbes(t *testing.T) {
+	readiness := &mockReadiness{report: health.Report{
+		Status: health.StatusDown,
+		Checks: map[string]health.Result{
+			"cache": {Status: health.StatusDown, Error: health.ErrWarmingUp.Error()},
+		},
+	}}
+	server := NewServer(&config.HTTPConfig{Port: "8080"}, newMockCache(), WithReadiness(readiness))
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Liveness should not depend on readiness, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	var report health.Report
+	json.NewDecoder(w.Body).Decode(&report)
+	if w.Code != http.StatusServiceUnavailable || report.Checks["cache"].Status != health.StatusDown {
+		t.Errorf("Expected 503 with the cache breakdown, got %d %+v", w.Code, report)
+	}
+
+	readiness.report = health.Report{Status: health.StatusUp}
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 once ready, got %d", w.Code)
+	}
+
+	server.Shutdown(context.Background())
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while shutting down, got %d", w.Code)
+	}
+}
+
+type slowCache struct {
+	*mockCache
+	delay time.Duration
+}
+
+func (m *slowCache) GetContext(ctx context.Context, orderUID string) (*models.Order, bool) {
+	time.Sleep(m.delay)
+	return m.mockCache.GetContext(ctx, orderUID)
+}
+
+func TestServerRequestTimeout(t *testing.T) {
+	hub := events.NewHub(&config.EventsConfig{History: 10, ClientBuffer: 10})
+	orders := &slowCache{mockCache: newMockCache(), delay: 50 * time.Millisecond}
+	server := NewServer(&config.HTTPConfig{Port: "0", RequestTimeout: 10 * time.Millisecond}, orders,
+		WithEvents(hub))
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/orders/MISSING", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a request over the timeout, got %d", w.Code)
+	}
+
+	server.SetRequestTimeout(0)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/orders/MISSING", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected no timeout once it is removed, got %d", w.Code)
+	}
+
+	server.SetRequestTimeout(10 * time.Millisecond)
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/api/orders/stream")
+	if err != nil {
+		t.Fatal("Error opening stream:", err)
+	}
+	defer resp.Body.Close()
+	waitForClients(t, hub, 1)
+	time.Sleep(30 * time.Millisecond)
+	hub.Publish(&models.Order{OrderUID: "A"}, true)
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if resp.StatusCode != http.StatusOK || err != nil || line == "" {
+		t.Errorf("Streams should outlive the request timeout, got %d %q %v", resp.StatusCode, line, err)
+	}
+	server.Shutdown(context.Background())
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"order-service/internal/events"
+	"order-service/internal/logging"
+)
+
+const streamHeartbeat = 15 * time.Second
+
+type Events interface {
+	Subscribe(lastID uint64, filter events.Filter) (*events.Client, []events.Event)
+	SetFilter(client *events.Client, filter events.Filter)
+	Unsubscribe(client *events.Client)
+}
+
+// handleOrderStream sends created and updated orders as Server-Sent
+// Events, optionally filtered by delivery_service, customer_id and
+// track_number. Browsers reconnect with Last-Event-ID and get the events
+// they missed, as far as the hub still retains them.
+func (s *Server) handleOrderStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID, err := lastEventID(r)
+	if err != nil {
+		http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+		return
+	}
+
+	client, backlog := s.events.Subscribe(lastID, streamFilter(r))
+	defer s.events.Unsubscribe(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	for _, event := range backlog {
+		if err := writeEvent(r.Context(), w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		case event, ok := <-client.C:
+			if !ok {
+				return
+			}
+			if err := writeEvent(r.Context(), w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func streamFilter(r *http.Request) events.Filter {
+	query := r.URL.Query()
+	return events.Filter{
+		DeliveryService: query.Get("delivery_service"),
+		CustomerID:      query.Get("customer_id"),
+		TrackNumber:     query.Get("track_number"),
+	}
+}
+
+func lastEventID(r *http.Request) (uint64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func writeEvent(ctx context.Context, w http.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(event.Order)
+	if err != nil {
+		slog.ErrorContext(ctx, "encoding event failed", "event_id", event.ID, logging.Err(err))
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,34 @@
+package http
+
+import (
+	"net/http"
+	"time"
+)
+
+// untimedRoutes stay open as long as the client or the work needs:
+// event streams, and reconciliation, which the verify command waits on
+// for minutes.
+var untimedRoutes = map[string]bool{
+	"/api/orders/stream":   true,
+	"/api/orders/ws":       true,
+	"/api/admin/reconcile": true,
+}
+
+// SetRequestTimeout changes the limit for requests started from now on.
+// Zero removes it.
+func (s *Server) SetRequestTimeout(timeout time.Duration) {
+	s.requestTimeout.Store(int64(timeout))
+}
+
+// limitDuration answers 503 to requests that outlive the request timeout
+// and cancels their context.
+func (s *Server) limitDuration(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := time.Duration(s.requestTimeout.Load())
+		if timeout <= 0 || untimedRoutes[routeTemplate(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.TimeoutHandler(next, timeout, "request timed out").ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"order-service/internal/models"
+	"order-service/internal/tracing"
+)
+
+// traceRequests starts a server span per routed request, continuing the
+// caller's trace from the traceparent header if there is one.
+func (s *Server) traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		ctx, span := tracing.Start(tracing.ExtractHTTP(r.Context(), r.Header), r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tmpl, err := current.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unknown"
+}
+
+// getOrder reads through the cache, which may fall back to the database.
+func (s *Server) getOrder(ctx context.Context, orderUID string) (*models.Order, bool) {
+	ctx, span := tracing.Start(ctx, "cache.Get", trace.WithAttributes(attribute.String("order.uid", orderUID)))
+	defer span.End()
+
+	order, ok := s.cache.GetContext(ctx, orderUID)
+	span.SetAttributes(attribute.Bool("order.found", ok))
+	return order, ok
+}
+
+func (s *Server) findBy(ctx context.Context, index, value string) []*models.Order {
+	_, span := tracing.Start(ctx, "cache.FindBy", trace.WithAttributes(attribute.String("cache.index", index)))
+	defer span.End()
+
+	matches := s.cache.FindBy(index, value)
+	span.SetAttributes(attribute.Int("orders.matched", len(matches)))
+	return matches
+}
+
+func (s *Server) rangeOrders(ctx context.Context, fn func(order *models.Order) bool) {
+	_, span := tracing.Start(ctx, "cache.Range")
+	defer span.End()
+	s.cache.Range(fn)
+}
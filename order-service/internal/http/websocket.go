@@ -0,0 +1,150 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"order-service/internal/events"
+	"order-service/internal/logging"
+	"order-service/internal/models"
+)
+
+const (
+	socketWriteWait  = 10 * time.Second
+	socketPongWait   = 60 * time.Second
+	socketPingPeriod = socketPongWait * 9 / 10
+	socketReadLimit  = 4096
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// socketMessage is sent to WebSocket clients: an order event, or the
+// confirmation of a subscribe request.
+type socketMessage struct {
+	ID     uint64         `json:"id,omitempty"`
+	Type   string         `json:"type"`
+	Order  *models.Order  `json:"order,omitempty"`
+	Filter *events.Filter `json:"filter,omitempty"`
+}
+
+// socketRequest is sent by clients to change their filter, e.g.
+// {"action":"subscribe","customer_id":"test"}.
+type socketRequest struct {
+	Action string `json:"action"`
+	events.Filter
+}
+
+// handleOrderSocket streams created and updated orders over a WebSocket.
+// The initial filter comes from the query string, as for the SSE stream,
+// and can be replaced with subscribe requests. Only this goroutine writes
+// to the connection; a client too slow to keep up is dropped by the hub
+// and disconnected with CloseTryAgainLater.
+func (s *Server) handleOrderSocket(w http.ResponseWriter, r *http.Request) {
+	lastID, err := lastEventID(r)
+	if err != nil {
+		http.Error(w, "Invalid last_event_id", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.WarnContext(r.Context(), "WebSocket upgrade failed", logging.Err(err))
+		return
+	}
+	defer conn.Close()
+
+	client, backlog := s.events.Subscribe(lastID, streamFilter(r))
+	defer s.events.Unsubscribe(client)
+
+	filters := make(chan events.Filter)
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go readSocket(r.Context(), conn, filters, done, stop)
+
+	for _, event := range backlog {
+		if err := writeSocket(conn, eventMessage(event)); err != nil {
+			return
+		}
+	}
+
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.closing:
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(socketWriteWait))
+			return
+		case filter := <-filters:
+			s.events.SetFilter(client, filter)
+			if err := writeSocket(conn, socketMessage{Type: "subscribed", Filter: &filter}); err != nil {
+				return
+			}
+		case event, ok := <-client.C:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"),
+					time.Now().Add(socketWriteWait))
+				return
+			}
+			if err := writeSocket(conn, eventMessage(event)); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readSocket handles client requests until the connection fails,
+// passing filter changes to the writing goroutine.
+func readSocket(ctx context.Context, conn *websocket.Conn, filters chan<- events.Filter, done chan<- struct{}, stop <-chan struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(socketReadLimit)
+	conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var req socketRequest
+		if err := json.Unmarshal(data, &req); err != nil || req.Action != "subscribe" {
+			slog.DebugContext(ctx, "ignoring WebSocket request", "request", string(data))
+			continue
+		}
+		select {
+		case filters <- req.Filter:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func writeSocket(conn *websocket.Conn, msg socketMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+	return conn.WriteJSON(msg)
+}
+
+func eventMessage(event events.Event) socketMessage {
+	return socketMessage{ID: event.ID, Type: event.Type, Order: event.Order}
+}
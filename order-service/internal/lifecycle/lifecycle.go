@@ -0,0 +1,57 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/logging"
+)
+
+type step struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager stops the service's components in the order they were added,
+// all under one shutdown deadline.
+type Manager struct {
+	timeout time.Duration
+	steps   []step
+}
+
+func NewManager(cfg *config.ShutdownConfig) *Manager {
+	return &Manager{timeout: cfg.Timeout}
+}
+
+// Add appends a step. stop should return once the component has
+// finished its work, or when ctx is done.
+func (m *Manager) Add(name string, stop func(ctx context.Context) error) {
+	m.steps = append(m.steps, step{name: name, stop: stop})
+}
+
+// Shutdown runs every step in order. A failed or timed-out step does not
+// skip the following ones, so connections are still closed; all errors
+// are returned together.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+
+	var errs []error
+	for _, s := range m.steps {
+		start := time.Now()
+		if err := s.stop(ctx); err != nil {
+			slog.Error("shutdown step failed", "step", s.name, logging.Err(err))
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		slog.Info("shutdown step done", "step", s.name, "duration", time.Since(start))
+	}
+	return errors.Join(errs...)
+}
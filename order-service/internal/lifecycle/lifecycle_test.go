@@ -0,0 +1,51 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/config"
+)
+
+func TestShutdownRunsStepsInOrder(t *testing.T) {
+	m := NewManager(&config.ShutdownConfig{Timeout: time.Second})
+
+	var order []string
+	for _, name := range []string{"subscriber", "http", "database"} {
+		m.Add(name, func(context.Context) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 3 || order[0] != "subscriber" || order[1] != "http" || order[2] != "database" {
+		t.Errorf("Unexpected step order %v", order)
+	}
+}
+
+func TestShutdownContinuesAfterFailure(t *testing.T) {
+	m := NewManager(&config.ShutdownConfig{Timeout: 50 * time.Millisecond})
+
+	closed := false
+	m.Add("subscriber", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	m.Add("database", func(context.Context) error {
+		closed = true
+		return nil
+	})
+
+	err := m.Shutdown(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the timed-out step to be reported, got %v", err)
+	}
+	if !closed {
+		t.Error("Database should be closed even though an earlier step timed out")
+	}
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"order-service/config"
+)
+
+func TestLoggerAddsContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&config.LogConfig{Level: "info", Format: FormatJSON}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = WithRequestID(ctx, "req-1")
+	ctx = WithOrderUID(ctx, "ORDER_1")
+
+	logger.ErrorContext(ctx, "save failed", Err(errors.New("boom")))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %q: %v", buf.String(), err)
+	}
+	want := map[string]string{
+		"msg":        "save failed",
+		KeyRequestID: "req-1",
+		KeyOrderUID:  "ORDER_1",
+		KeyError:     "boom",
+		"trace_id":   traceID.String(),
+		"span_id":    spanID.String(),
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("Expected %s=%q, got %v", key, value, record[key])
+		}
+	}
+	if RequestID(ctx) != "req-1" {
+		t.Errorf("Expected request ID from context, got %q", RequestID(ctx))
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&config.LogConfig{Level: "warn", Format: FormatText}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("Expected info to be filtered at warn level, got %q", buf.String())
+	}
+	logger.Warn("shown")
+	if !bytes.Contains(buf.Bytes(), []byte("msg=shown")) {
+		t.Errorf("Expected a text record, got %q", buf.String())
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	for _, cfg := range []config.LogConfig{
+		{Level: "loud", Format: FormatJSON},
+		{Level: "info", Format: "xml"},
+	} {
+		if _, err := New(&cfg, &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestWithDoesNotShareAttrs(t *testing.T) {
+	base := With(context.Background(), slog.String("a", "1"))
+	first := With(base, slog.String("b", "2"))
+	second := With(base, slog.String("c", "3"))
+
+	if got := first.Value(attrsKey{}).([]slog.Attr); len(got) != 2 || got[1].Key != "b" {
+		t.Errorf("Unexpected attrs %v", got)
+	}
+	if got := second.Value(attrsKey{}).([]slog.Attr); len(got) != 2 || got[1].Key != "c" {
+		t.Errorf("Unexpected attrs %v", got)
+	}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheEntriesDesc = prometheus.NewDesc(namespace+"_cache_entries",
+		"Orders held in the cache.", nil, nil)
+	cacheBytesDesc = prometheus.NewDesc(namespace+"_cache_bytes",
+		"Approximate size of the cached orders in bytes.", nil, nil)
+	cacheHitsDesc = prometheus.NewDesc(namespace+"_cache_hits_total",
+		"Cache lookups served from memory.", nil, nil)
+	cacheMissesDesc = prometheus.NewDesc(namespace+"_cache_misses_total",
+		"Cache lookups not found in memory.", nil, nil)
+	cacheEvictionsDesc = prometheus.NewDesc(namespace+"_cache_evictions_total",
+		"Orders evicted to stay within the cache limits.", nil, nil)
+	cacheExpirationsDesc = prometheus.NewDesc(namespace+"_cache_expirations_total",
+		"Orders dropped from the cache after their TTL.", nil, nil)
+)
+
+// cacheCollector reads cache.Stats on every scrape rather than mirroring
+// each cache operation into separate counters.
+type cacheCollector struct {
+	stats CacheStats
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheEntriesDesc
+	ch <- cacheBytesDesc
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheEvictionsDesc
+	ch <- cacheExpirationsDesc
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats.Stats()
+	ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(s.Entries))
+	ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, float64(s.Bytes))
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(s.Evictions))
+	ch <- prometheus.MustNewConstMetric(cacheExpirationsDesc, prometheus.CounterValue, float64(s.Expirations))
+}
+
+func statusLabel(status int) string {
+	if status == 0 {
+		status = 200
+	}
+	return strconv.Itoa(status)
+}
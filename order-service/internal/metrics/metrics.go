@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"order-service/internal/cache"
+)
+
+const namespace = "order_service"
+
+// Message outcomes recorded by MessageFailed.
+const (
+	ReasonMalformed = "malformed"
+	ReasonInvalid   = "invalid"
+	ReasonStale     = "stale"
+	ReasonSaveError = "save_error"
+)
+
+// Save results recorded by ObserveSave.
+const (
+	SaveOK        = "ok"
+	SaveDuplicate = "duplicate"
+	SaveStale     = "stale"
+	SaveError     = "error"
+)
+
+// Metrics holds the service's collectors in a registry of its own, so
+// tests can create as many as they need. Every method is safe to call on
+// a nil *Metrics, which records nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	messagesReceived    prometheus.Counter
+	messagesRedelivered prometheus.Counter
+	messagesAcked       prometheus.Counter
+	messagesNacked      prometheus.Counter
+	messagesFailed      *prometheus.CounterVec
+	messagesDeadLetter  prometheus.Counter
+
+	saveDuration *prometheus.HistogramVec
+	saveErrors   *prometheus.CounterVec
+
+	httpDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		messagesReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "messages", Name: "received_total",
+			Help: "Broker messages received by the subscriber.",
+		}),
+		messagesRedelivered: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "messages", Name: "redelivered_total",
+			Help: "Broker messages received again after an earlier delivery.",
+		}),
+		messagesAcked: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "messages", Name: "acked_total",
+			Help: "Broker messages acknowledged, including duplicates and dead letters.",
+		}),
+		messagesNacked: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "messages", Name: "nacked_total",
+			Help: "Broker messages handed back for redelivery.",
+		}),
+		messagesFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "messages", Name: "failed_total",
+			Help: "Broker messages that could not be ingested, by reason.",
+		}, []string{"reason"}),
+		messagesDeadLetter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "messages", Name: "dead_lettered_total",
+			Help: "Broker messages moved to dead letters.",
+		}),
+		saveDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "db", Name: "save_order_duration_seconds",
+			Help:    "SaveOrder latency by result.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"result"}),
+		saveErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "db", Name: "save_order_errors_total",
+			Help: "SaveOrder calls that did not store the order, by result.",
+		}, []string{"result"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+			Help:    "HTTP request duration by method, route template and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.messagesReceived,
+		m.messagesRedelivered,
+		m.messagesAcked,
+		m.messagesNacked,
+		m.messagesFailed,
+		m.messagesDeadLetter,
+		m.saveDuration,
+		m.saveErrors,
+		m.httpDuration,
+	)
+	return m
+}
+
+func (m *Metrics) Registry() *prometheus.Registry {
+	if m == nil {
+		return nil
+	}
+	return m.registry
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// MessageReceived counts a delivery, and a redelivery if it is not the
+// first one.
+func (m *Metrics) MessageReceived(redeliveries int) {
+	if m == nil {
+		return
+	}
+	m.messagesReceived.Inc()
+	if redeliveries > 0 {
+		m.messagesRedelivered.Inc()
+	}
+}
+
+func (m *Metrics) MessageAcked() {
+	if m == nil {
+		return
+	}
+	m.messagesAcked.Inc()
+}
+
+func (m *Metrics) MessageNacked() {
+	if m == nil {
+		return
+	}
+	m.messagesNacked.Inc()
+}
+
+func (m *Metrics) MessageFailed(reason string) {
+	if m == nil {
+		return
+	}
+	m.messagesFailed.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) MessageDeadLettered() {
+	if m == nil {
+		return
+	}
+	m.messagesDeadLetter.Inc()
+}
+
+// ObserveSave records a SaveOrder call that took d. Any result other
+// than SaveOK also counts as an error.
+func (m *Metrics) ObserveSave(result string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.saveDuration.WithLabelValues(result).Observe(d.Seconds())
+	if result != SaveOK {
+		m.saveErrors.WithLabelValues(result).Inc()
+	}
+}
+
+func (m *Metrics) ObserveHTTP(method, route string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpDuration.WithLabelValues(method, route, statusLabel(status)).Observe(d.Seconds())
+}
+
+type CacheStats interface {
+	Stats() cache.Stats
+}
+
+// RegisterCache exports the cache's own counters, read at scrape time.
+func (m *Metrics) RegisterCache(stats CacheStats) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&cacheCollector{stats: stats})
+}
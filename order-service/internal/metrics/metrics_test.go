@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"order-service/internal/cache"
+)
+
+type fakeStats cache.Stats
+
+func (f fakeStats) Stats() cache.Stats {
+	return cache.Stats(f)
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.MessageReceived(1)
+	m.MessageAcked()
+	m.MessageNacked()
+	m.MessageFailed(ReasonInvalid)
+	m.MessageDeadLettered()
+	m.ObserveSave(SaveOK, time.Millisecond)
+	m.ObserveHTTP("GET", "/", 200, time.Millisecond)
+	m.RegisterCache(fakeStats{})
+	if m.Registry() != nil {
+		t.Error("Nil metrics should have no registry")
+	}
+}
+
+func TestObserveSave(t *testing.T) {
+	m := New()
+	m.ObserveSave(SaveOK, time.Millisecond)
+	m.ObserveSave(SaveDuplicate, time.Millisecond)
+	m.ObserveSave(SaveError, time.Millisecond)
+
+	if n := testutil.CollectAndCount(m.saveDuration); n != 3 {
+		t.Errorf("Expected a latency series per result, got %d", n)
+	}
+	if v := testutil.ToFloat64(m.saveErrors.WithLabelValues(SaveDuplicate)); v != 1 {
+		t.Errorf("Expected 1 duplicate error, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.saveErrors.WithLabelValues(SaveOK)); v != 0 {
+		t.Errorf("Successful saves must not count as errors, got %v", v)
+	}
+}
+
+func TestCacheCollector(t *testing.T) {
+	m := New()
+	m.RegisterCache(fakeStats{Entries: 3, Bytes: 1024, Hits: 10, Misses: 2, Evictions: 1})
+
+	expected := `
+# HELP order_service_cache_entries Orders held in the cache.
+# TYPE order_service_cache_entries gauge
+order_service_cache_entries 3
+# HELP order_service_cache_evictions_total Orders evicted to stay within the cache limits.
+# TYPE order_service_cache_evictions_total counter
+order_service_cache_evictions_total 1
+# HELP order_service_cache_hits_total Cache lookups served from memory.
+# TYPE order_service_cache_hits_total counter
+order_service_cache_hits_total 10
+# HELP order_service_cache_misses_total Cache lookups not found in memory.
+# TYPE order_service_cache_misses_total counter
+order_service_cache_misses_total 2
+`
+	err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected),
+		"order_service_cache_entries",
+		"order_service_cache_evictions_total",
+		"order_service_cache_hits_total",
+		"order_service_cache_misses_total",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type DeadLetter struct {
+	ID           int64     `json:"id" db:"id"`
+	Subject      string    `json:"subject" db:"subject"`
+	Sequence     uint64    `json:"sequence" db:"sequence"`
+	Redeliveries int       `json:"redeliveries" db:"redeliveries"`
+	Payload      string    `json:"payload" db:"payload"`
+	Error        string    `json:"error" db:"error"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
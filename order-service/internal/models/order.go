@@ -17,6 +17,7 @@ type Order struct {
 	SmID              int       `json:"sm_id" db:"sm_id"`
 	DateCreated       time.Time `json:"date_created" db:"date_created"`
 	OofShard          string    `json:"oof_shard" db:"oof_shard"`
+	Version           int64     `json:"version" db:"version"`
 }
 
 type Delivery struct {
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const EventOrderAccepted = "order.accepted"
+
+type OutboxMessage struct {
+	ID        int64  `json:"id" db:"id"`
+	EventType string `json:"event_type" db:"event_type"`
+	Payload   []byte `json:"payload" db:"payload"`
+	// Headers carry the trace context of the request that saved the order.
+	Headers   map[string]string `json:"headers,omitempty" db:"headers"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+}
+
+// OrderAccepted is published once an order version has been committed.
+// Delivery is at-least-once; consumers deduplicate by order UID and
+// version.
+type OrderAccepted struct {
+	OrderUID    string    `json:"order_uid"`
+	Version     int64     `json:"version"`
+	TrackNumber string    `json:"track_number"`
+	CustomerID  string    `json:"customer_id"`
+	AcceptedAt  time.Time `json:"accepted_at"`
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+var (
+	phonePattern    = regexp.MustCompile(`^\+?[0-9]{10,15}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Field+": "+v.Message)
+	}
+	return "order validation failed: " + strings.Join(parts, "; ")
+}
+
+type validator struct {
+	violations []Violation
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+	}
+}
+
+func (v *validator) nonNegative(field string, value int) {
+	if value < 0 {
+		v.add(field, "must not be negative, got %d", value)
+	}
+}
+
+// Validate checks required fields, formats and cross-field rules.
+// It returns *ValidationError listing every violation found, or nil.
+func (o *Order) Validate() error {
+	v := &validator{}
+
+	v.required("order_uid", o.OrderUID)
+	v.required("track_number", o.TrackNumber)
+	v.required("entry", o.Entry)
+	v.required("customer_id", o.CustomerID)
+	v.required("delivery_service", o.DeliveryService)
+	if o.DateCreated.IsZero() {
+		v.add("date_created", "is required")
+	}
+	if o.Version < 0 {
+		v.add("version", "must not be negative, got %d", o.Version)
+	}
+
+	o.Delivery.validate(v)
+	o.Payment.validate(v)
+
+	if len(o.Items) == 0 {
+		v.add("items", "must contain at least one item")
+	}
+	for i, item := range o.Items {
+		prefix := fmt.Sprintf("items[%d].", i)
+		item.validate(v, prefix)
+		if item.TrackNumber != "" && o.TrackNumber != "" && item.TrackNumber != o.TrackNumber {
+			v.add(prefix+"track_number", "must match order track_number %q, got %q", o.TrackNumber, item.TrackNumber)
+		}
+	}
+
+	if len(v.violations) > 0 {
+		return &ValidationError{Violations: v.violations}
+	}
+	return nil
+}
+
+func (d *Delivery) validate(v *validator) {
+	v.required("delivery.name", d.Name)
+	v.required("delivery.city", d.City)
+	v.required("delivery.address", d.Address)
+
+	if d.Phone == "" {
+		v.add("delivery.phone", "is required")
+	} else if !phonePattern.MatchString(d.Phone) {
+		v.add("delivery.phone", "must be 10-15 digits with optional leading '+', got %q", d.Phone)
+	}
+
+	if d.Email != "" {
+		if addr, err := mail.ParseAddress(d.Email); err != nil || addr.Address != d.Email {
+			v.add("delivery.email", "is not a valid email address: %q", d.Email)
+		}
+	}
+}
+
+func (p *Payment) validate(v *validator) {
+	v.required("payment.transaction", p.Transaction)
+	v.required("payment.provider", p.Provider)
+	v.required("payment.bank", p.Bank)
+
+	if p.Currency == "" {
+		v.add("payment.currency", "is required")
+	} else if !currencyPattern.MatchString(p.Currency) {
+		v.add("payment.currency", "must be a 3-letter ISO 4217 code, got %q", p.Currency)
+	}
+
+	if p.Amount <= 0 {
+		v.add("payment.amount", "must be positive, got %d", p.Amount)
+	}
+	if p.PaymentDt <= 0 {
+		v.add("payment.payment_dt", "must be a positive unix timestamp, got %d", p.PaymentDt)
+	}
+	v.nonNegative("payment.delivery_cost", p.DeliveryCost)
+	v.nonNegative("payment.goods_total", p.GoodsTotal)
+	v.nonNegative("payment.custom_fee", p.CustomFee)
+}
+
+func (i *Item) validate(v *validator, prefix string) {
+	v.required(prefix+"track_number", i.TrackNumber)
+	v.required(prefix+"rid", i.Rid)
+	v.required(prefix+"name", i.Name)
+	v.required(prefix+"brand", i.Brand)
+
+	v.nonNegative(prefix+"price", i.Price)
+	v.nonNegative(prefix+"total_price", i.TotalPrice)
+	if i.Sale < 0 || i.Sale > 100 {
+		v.add(prefix+"sale", "must be between 0 and 100, got %d", i.Sale)
+	}
+	if i.TotalPrice > i.Price {
+		v.add(prefix+"total_price", "must not exceed price %d, got %d", i.Price, i.TotalPrice)
+	}
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func validOrder() *Order {
+	return &Order{
+		OrderUID:        "b563feb7b2b84b6test",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Delivery: Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+	}
+}
+
+func violationFields(t *testing.T, err error) map[string]bool {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	fields := make(map[string]bool, len(verr.Violations))
+	for _, v := range verr.Violations {
+		fields[v.Field] = true
+	}
+	return fields
+}
+
+func TestValidateValidOrder(t *testing.T) {
+	if err := validOrder().Validate(); err != nil {
+		t.Fatalf("Valid order rejected: %v", err)
+	}
+}
+
+func TestValidateRequiredFields(t *testing.T) {
+	order := validOrder()
+	order.OrderUID = ""
+	order.Items = nil
+	order.Payment = Payment{}
+
+	fields := violationFields(t, order.Validate())
+	for _, field := range []string{"order_uid", "items", "payment.transaction", "payment.currency", "payment.amount"} {
+		if !fields[field] {
+			t.Errorf("Expected violation for %s", field)
+		}
+	}
+}
+
+func TestValidateFormats(t *testing.T) {
+	order := validOrder()
+	order.Delivery.Email = "not-an-email"
+	order.Delivery.Phone = "12-34"
+	order.Payment.Currency = "usd"
+
+	fields := violationFields(t, order.Validate())
+	for _, field := range []string{"delivery.email", "delivery.phone", "payment.currency"} {
+		if !fields[field] {
+			t.Errorf("Expected violation for %s", field)
+		}
+	}
+}
+
+func TestValidateItems(t *testing.T) {
+	order := validOrder()
+	order.Items[0].Price = -1
+	order.Items = append(order.Items, order.Items[0])
+	order.Items[1].Price = 100
+	order.Items[1].TrackNumber = "OTHER"
+
+	fields := violationFields(t, order.Validate())
+	for _, field := range []string{"items[0].price", "items[1].track_number", "items[1].total_price"} {
+		if !fields[field] {
+			t.Errorf("Expected violation for %s", field)
+		}
+	}
+}
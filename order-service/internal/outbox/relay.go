@@ -0,0 +1,130 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"order-service/config"
+	"order-service/internal/logging"
+	"order-service/internal/models"
+	"order-service/internal/tracing"
+)
+
+const cleanupInterval = time.Hour
+
+type Store interface {
+	ProcessOutbox(limit int, publish func(msg *models.OutboxMessage) error) (int, error)
+	DeletePublishedOutbox(before time.Time) (int64, error)
+}
+
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// HeaderPublisher is implemented by publishers whose transport carries
+// headers; only those pass the trace context on to consumers.
+type HeaderPublisher interface {
+	PublishWithHeaders(subject string, data []byte, headers map[string]string) error
+}
+
+// Relay publishes outbox messages written by SaveOrder. A message is
+// marked published only after the broker accepted it, so a crash in
+// between sends it again: delivery is at-least-once.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	subject   string
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+}
+
+func NewRelay(store Store, publisher Publisher, cfg *config.OutboxConfig) *Relay {
+	r := &Relay{
+		store:     store,
+		publisher: publisher,
+		subject:   cfg.Subject,
+		interval:  cfg.PollInterval,
+		batchSize: cfg.BatchSize,
+		retention: cfg.Retention,
+	}
+	if r.interval <= 0 {
+		r.interval = time.Second
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = 100
+	}
+	return r
+}
+
+// Run polls the outbox until ctx is cancelled. Full batches are followed
+// by another poll straight away to drain a backlog.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Now()
+	for {
+		for r.relay() == r.batchSize {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		if time.Since(lastCleanup) >= cleanupInterval {
+			r.cleanup()
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) relay() int {
+	n, err := r.store.ProcessOutbox(r.batchSize, r.publish)
+	if err != nil {
+		slog.Error("outbox relay failed", "published", n, logging.Err(err))
+		return 0
+	}
+	if n > 0 {
+		slog.Debug("outbox relay published messages", "count", n, logging.KeySubject, r.subject)
+	}
+	return n
+}
+
+// publish continues the trace of the request that saved the order.
+func (r *Relay) publish(msg *models.OutboxMessage) (err error) {
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), msg.Headers), "outbox.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", r.subject),
+			attribute.Int64("outbox.id", msg.ID),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if hp, ok := r.publisher.(HeaderPublisher); ok {
+		if headers := tracing.Inject(ctx); headers != nil {
+			return hp.PublishWithHeaders(r.subject, msg.Payload, headers)
+		}
+	}
+	return r.publisher.Publish(r.subject, msg.Payload)
+}
+
+func (r *Relay) cleanup() {
+	n, err := r.store.DeletePublishedOutbox(time.Now().Add(-r.retention))
+	if err != nil {
+		slog.Error("outbox cleanup failed", logging.Err(err))
+		return
+	}
+	if n > 0 {
+		slog.Info("outbox cleanup removed published messages", "count", n)
+	}
+}
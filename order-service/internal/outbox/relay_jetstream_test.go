@@ -0,0 +1,77 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"order-service/config"
+	"order-service/internal/broker"
+)
+
+// JetStream only acknowledges publishes to subjects a stream captures, so
+// the relay depends on the broker adding the outbox subject to its stream.
+func TestRelayPublishesToJetStream(t *testing.T) {
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal("Error creating NATS server:", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+	t.Cleanup(srv.Shutdown)
+
+	cfg := testConfig()
+	publisher, err := broker.NewJetStream(&config.NATSConfig{
+		URL:      srv.ClientURL(),
+		ClientID: "order-service-test",
+		Subject:  "orders",
+		Stream:   "ORDERS",
+	}, &config.BrokerConfig{}, cfg.Subject)
+	if err != nil {
+		t.Fatal("Error connecting to JetStream:", err)
+	}
+	t.Cleanup(func() { publisher.Close() })
+
+	store := newMockStore(3)
+	relay := NewRelay(store, publisher, cfg)
+	relay.relay()
+	relay.relay()
+	if store.pending() != 0 {
+		t.Fatalf("Expected the outbox to be drained, got %d pending", store.pending())
+	}
+
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal("Error connecting to NATS:", err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal("Error opening JetStream:", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := js.Stream(ctx, "ORDERS")
+	if err != nil {
+		t.Fatal("Error reading stream:", err)
+	}
+	msg, err := stream.GetLastMsgForSubject(ctx, cfg.Subject)
+	if err != nil {
+		t.Fatal("Expected outbox messages in the stream:", err)
+	}
+	if string(msg.Data) != `{"order_uid":"ORDER_3"}` {
+		t.Errorf("Unexpected last message: %s", msg.Data)
+	}
+}
@@ -0,0 +1,194 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/models"
+	"order-service/internal/tracing"
+	"order-service/internal/tracing/tracingtest"
+)
+
+type mockStore struct {
+	mu        sync.Mutex
+	messages  []*models.OutboxMessage
+	published map[int64]bool
+}
+
+func newMockStore(n int) *mockStore {
+	s := &mockStore{published: make(map[int64]bool)}
+	for i := 1; i <= n; i++ {
+		s.messages = append(s.messages, &models.OutboxMessage{
+			ID:        int64(i),
+			EventType: models.EventOrderAccepted,
+			Payload:   []byte(fmt.Sprintf(`{"order_uid":"ORDER_%d"}`, i)),
+		})
+	}
+	return s
+}
+
+func (s *mockStore) ProcessOutbox(limit int, publish func(msg *models.OutboxMessage) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, msg := range s.messages {
+		if n == limit {
+			break
+		}
+		if s.published[msg.ID] {
+			continue
+		}
+		if err := publish(msg); err != nil {
+			return n, err
+		}
+		s.published[msg.ID] = true
+		n++
+	}
+	return n, nil
+}
+
+func (s *mockStore) DeletePublishedOutbox(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (s *mockStore) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.messages) - len(s.published)
+}
+
+type mockPublisher struct {
+	mu       sync.Mutex
+	failures int
+	subjects []string
+	payloads []string
+}
+
+func (p *mockPublisher) Publish(subject string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	p.subjects = append(p.subjects, subject)
+	p.payloads = append(p.payloads, string(data))
+	return nil
+}
+
+func (p *mockPublisher) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.payloads)
+}
+
+func testConfig() *config.OutboxConfig {
+	return &config.OutboxConfig{
+		Subject:      "order.accepted",
+		PollInterval: 10 * time.Millisecond,
+		BatchSize:    2,
+		Retention:    time.Hour,
+	}
+}
+
+func TestRelayPublishesOnce(t *testing.T) {
+	store := newMockStore(3)
+	publisher := &mockPublisher{}
+	relay := NewRelay(store, publisher, testConfig())
+
+	relay.relay()
+	relay.relay()
+	relay.relay()
+
+	if publisher.count() != 3 || store.pending() != 0 {
+		t.Fatalf("Expected 3 published and none pending, got %d published, %d pending", publisher.count(), store.pending())
+	}
+	if publisher.subjects[0] != "order.accepted" || publisher.payloads[0] != `{"order_uid":"ORDER_1"}` {
+		t.Errorf("Unexpected first message: %s %s", publisher.subjects[0], publisher.payloads[0])
+	}
+}
+
+func TestRelayRetriesAfterPublishError(t *testing.T) {
+	store := newMockStore(1)
+	publisher := &mockPublisher{failures: 1}
+	relay := NewRelay(store, publisher, testConfig())
+
+	relay.relay()
+	if store.pending() != 1 {
+		t.Fatal("Message must stay pending when publishing fails")
+	}
+
+	relay.relay()
+	if store.pending() != 0 || publisher.count() != 1 {
+		t.Errorf("Message should be published on retry, got %d pending, %d published", store.pending(), publisher.count())
+	}
+}
+
+func TestRelayRunDrainsBacklog(t *testing.T) {
+	store := newMockStore(7)
+	publisher := &mockPublisher{}
+	relay := NewRelay(store, publisher, testConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for store.pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if store.pending() != 0 || publisher.count() != 7 {
+		t.Errorf("Expected all 7 messages published once, got %d pending, %d published", store.pending(), publisher.count())
+	}
+}
+
+type headerPublisher struct {
+	mockPublisher
+	headers []map[string]string
+}
+
+func (p *headerPublisher) PublishWithHeaders(subject string, data []byte, headers map[string]string) error {
+	p.headers = append(p.headers, headers)
+	return p.Publish(subject, data)
+}
+
+func TestRelayPropagatesTraceContext(t *testing.T) {
+	exporter := tracingtest.InMemory()
+	ctx, span := tracing.Start(context.Background(), "SaveOrder")
+	span.End()
+
+	store := newMockStore(2)
+	store.messages[0].Headers = tracing.Inject(ctx)
+	publisher := &headerPublisher{}
+	NewRelay(store, publisher, testConfig()).relay()
+
+	if len(publisher.headers) != 2 {
+		t.Fatalf("Expected 2 messages published with headers, got %d", len(publisher.headers))
+	}
+	published := exporter.GetSpans()
+	if len(published) != 3 || published[1].Name != "outbox.publish" {
+		t.Fatalf("Expected a publish span per message, got %d spans", len(published))
+	}
+	if published[1].SpanContext.TraceID() != span.SpanContext().TraceID() {
+		t.Error("Publish span should continue the trace stored with the message")
+	}
+	if published[2].SpanContext.TraceID() == span.SpanContext().TraceID() {
+		t.Error("A message without headers should start a new trace")
+	}
+	want := "00-" + published[1].SpanContext.TraceID().String() + "-" + published[1].SpanContext.SpanID().String() + "-01"
+	if publisher.headers[0]["traceparent"] != want {
+		t.Errorf("Expected traceparent %s, got %v", want, publisher.headers[0])
+	}
+}
@@ -0,0 +1,242 @@
+package reconcile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/database"
+	"order-service/internal/logging"
+	"order-service/internal/models"
+)
+
+type Cache interface {
+	Range(fn func(order *models.Order) bool)
+	Peek(orderUID string) (*models.Order, bool)
+	Replace(order *models.Order)
+	Delete(orderUID string) bool
+	Bounded() bool
+	Ready() bool
+}
+
+var (
+	ErrWarmingUp  = errors.New("cache warm-up in progress")
+	ErrInProgress = errors.New("reconciliation already in progress")
+)
+
+type Database interface {
+	IterateOrders(ctx context.Context, since time.Time, batchSize int, fn func(batch []*models.Order) error) error
+	GetOrder(ctx context.Context, orderUID string) (*models.Order, error)
+}
+
+// Report lists the order UIDs on which the cache and the database
+// disagree. Missing orders are only reported for an unbounded cache,
+// since a bounded one, or one restored from a window of recent days, is
+// expected to hold a subset.
+type Report struct {
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	CacheOrders    int       `json:"cache_orders"`
+	DatabaseOrders int       `json:"database_orders"`
+	Missing        []string  `json:"missing"`
+	Extra          []string  `json:"extra"`
+	Diverged       []string  `json:"diverged"`
+	Repaired       int       `json:"repaired"`
+	Error          string    `json:"error,omitempty"`
+}
+
+func (r *Report) Consistent() bool {
+	return r.Error == "" && len(r.Missing)+len(r.Extra)+len(r.Diverged) == r.Repaired
+}
+
+// Reconciler compares the cache with the database, which is the source
+// of truth, and can repair the cache to match it.
+type Reconciler struct {
+	cache     Cache
+	db        Database
+	interval  time.Duration
+	repair    bool
+	batchSize int
+
+	run  sync.Mutex
+	mu   sync.RWMutex
+	last *Report
+}
+
+func NewReconciler(cache Cache, db Database, cfg *config.ReconcileConfig) *Reconciler {
+	r := &Reconciler{
+		cache:     cache,
+		db:        db,
+		interval:  cfg.Interval,
+		repair:    cfg.Repair,
+		batchSize: cfg.BatchSize,
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = 1000
+	}
+	return r
+}
+
+// Run checks every interval until ctx is cancelled, repairing if
+// configured to. A zero interval disables the job.
+func (r *Reconciler) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := r.Check(ctx, r.repair)
+		if errors.Is(err, ErrInProgress) {
+			continue
+		}
+		if err != nil {
+			slog.Error("reconciliation failed", logging.Err(err))
+			continue
+		}
+		if !report.Consistent() {
+			slog.Warn("cache drift detected",
+				"missing", len(report.Missing),
+				"extra", len(report.Extra),
+				"diverged", len(report.Diverged),
+				"repaired", report.Repaired,
+			)
+		}
+	}
+}
+
+// LastReport returns the result of the latest check, or nil.
+func (r *Reconciler) LastReport() *Report {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.last
+}
+
+// Check compares order UIDs and content hashes between the cache and the
+// database. Writes racing with the scan can look like drift, so each
+// difference is confirmed against the current state of both before it is
+// reported. With repair, the cache is made to match the database. Only
+// one check runs at a time; others return ErrInProgress without a report.
+func (r *Reconciler) Check(ctx context.Context, repair bool) (*Report, error) {
+	if !r.run.TryLock() {
+		return nil, ErrInProgress
+	}
+	defer r.run.Unlock()
+
+	report := &Report{StartedAt: time.Now(), Missing: []string{}, Extra: []string{}, Diverged: []string{}}
+	err := r.check(ctx, report, repair)
+	report.FinishedAt = time.Now()
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	r.last = report
+	r.mu.Unlock()
+	return report, err
+}
+
+func (r *Reconciler) check(ctx context.Context, report *Report, repair bool) error {
+	if !r.cache.Ready() {
+		return ErrWarmingUp
+	}
+
+	cached := make(map[string]*models.Order)
+	r.cache.Range(func(order *models.Order) bool {
+		cached[order.OrderUID] = order
+		return true
+	})
+	report.CacheOrders = len(cached)
+
+	var candidates []string
+	checkMissing := !r.cache.Bounded()
+	err := r.db.IterateOrders(ctx, time.Time{}, r.batchSize, func(batch []*models.Order) error {
+		for _, order := range batch {
+			report.DatabaseOrders++
+			c, ok := cached[order.OrderUID]
+			delete(cached, order.OrderUID)
+			if (!ok && checkMissing) || (ok && Hash(c) != Hash(order)) {
+				candidates = append(candidates, order.OrderUID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for uid := range cached {
+		candidates = append(candidates, uid)
+	}
+	sort.Strings(candidates)
+
+	for _, uid := range candidates {
+		if err := r.confirm(ctx, report, uid, repair, checkMissing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) confirm(ctx context.Context, report *Report, uid string, repair, checkMissing bool) error {
+	stored, err := r.db.GetOrder(ctx, uid)
+	if err != nil && !errors.Is(err, database.ErrOrderNotFound) {
+		return err
+	}
+	cached, _ := r.cache.Peek(uid)
+
+	switch {
+	case stored == nil && cached != nil:
+		report.Extra = append(report.Extra, uid)
+		if repair {
+			r.cache.Delete(uid)
+			report.Repaired++
+		}
+	case stored != nil && cached == nil:
+		if !checkMissing {
+			return nil
+		}
+		report.Missing = append(report.Missing, uid)
+		if repair {
+			r.cache.Replace(stored)
+			report.Repaired++
+		}
+	case stored != nil && Hash(stored) != Hash(cached):
+		report.Diverged = append(report.Diverged, uid)
+		if repair {
+			r.cache.Replace(stored)
+			report.Repaired++
+		}
+	}
+	return nil
+}
+
+// Hash is a content hash of order that is stable across a database round
+// trip: the creation time is taken as wall clock at microsecond
+// precision, as Postgres stores it, and empty item lists compare equal.
+func Hash(order *models.Order) string {
+	normalized := *order
+	t := order.DateCreated
+	normalized.DateCreated = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+		t.Nanosecond()/1000*1000, time.UTC)
+	if len(normalized.Items) == 0 {
+		normalized.Items = nil
+	}
+
+	data, _ := json.Marshal(normalized)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
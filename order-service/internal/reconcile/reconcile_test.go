@@ -0,0 +1,175 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/cache"
+	"order-service/internal/database"
+	"order-service/internal/models"
+)
+
+type mockDatabase struct {
+	orders map[string]*models.Order
+}
+
+func (m *mockDatabase) IterateOrders(ctx context.Context, since time.Time, batchSize int, fn func(batch []*models.Order) error) error {
+	var batch []*models.Order
+	for _, order := range m.orders {
+		batch = append(batch, order)
+	}
+	return fn(batch)
+}
+
+func (m *mockDatabase) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	if order, ok := m.orders[orderUID]; ok {
+		return order, nil
+	}
+	return nil, database.ErrOrderNotFound
+}
+
+func order(uid string, version int64) *models.Order {
+	return &models.Order{
+		OrderUID:    uid,
+		Version:     version,
+		DateCreated: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Items:       []models.Item{{Rid: uid + "_1"}},
+	}
+}
+
+func newTestCache(t *testing.T, db *mockDatabase, opts ...cache.Option) *cache.Cache {
+	t.Helper()
+	c := cache.NewCache(opts...)
+	if err := c.RestoreFromDB(context.Background(), db, &config.CacheConfig{RestoreBatchSize: 100}); err != nil {
+		t.Fatal("Error restoring cache:", err)
+	}
+	return c
+}
+
+func TestReconcilerFindsAndRepairsDrift(t *testing.T) {
+	db := &mockDatabase{orders: map[string]*models.Order{
+		"SAME":     order("SAME", 1),
+		"MISSING":  order("MISSING", 1),
+		"DIVERGED": order("DIVERGED", 1),
+	}}
+	c := newTestCache(t, db)
+	c.Delete("MISSING")
+	c.Set(order("DIVERGED", 2))
+	c.Set(order("EXTRA", 1))
+
+	r := NewReconciler(c, db, &config.ReconcileConfig{})
+	report, err := r.Check(context.Background(), false)
+	if err != nil {
+		t.Fatal("Error checking:", err)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != "MISSING" ||
+		len(report.Extra) != 1 || report.Extra[0] != "EXTRA" ||
+		len(report.Diverged) != 1 || report.Diverged[0] != "DIVERGED" {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	if report.Consistent() || report.Repaired != 0 {
+		t.Errorf("Check without repair must not change the cache: %+v", report)
+	}
+
+	if report, _ := r.Check(context.Background(), true); report.Repaired != 3 {
+		t.Errorf("Expected 3 repairs, got %+v", report)
+	}
+	if report, _ := r.Check(context.Background(), false); !report.Consistent() || len(report.Diverged) != 0 {
+		t.Errorf("Cache should match the database after repair: %+v", report)
+	}
+	if cached, _ := c.Peek("DIVERGED"); cached.Version != 1 {
+		t.Errorf("Repair should restore the database version, got %d", cached.Version)
+	}
+	if r.LastReport() == nil {
+		t.Error("LastReport should return the latest check")
+	}
+}
+
+func TestReconcilerIgnoresMissingInBoundedCache(t *testing.T) {
+	db := &mockDatabase{orders: map[string]*models.Order{"A": order("A", 0), "B": order("B", 0)}}
+	c := newTestCache(t, db, cache.WithLimits(cache.Limits{MaxEntries: 1}))
+
+	report, err := NewReconciler(c, db, &config.ReconcileConfig{}).Check(context.Background(), false)
+	if err != nil || !report.Consistent() {
+		t.Errorf("Evicted orders are not drift: %+v, %v", report, err)
+	}
+}
+
+func TestReconcilerIgnoresMissingOutsideRestoreWindow(t *testing.T) {
+	db := &mockDatabase{orders: map[string]*models.Order{"OLD": order("OLD", 0)}}
+	c := cache.NewCache()
+	empty := &mockDatabase{orders: map[string]*models.Order{}}
+	if err := c.RestoreFromDB(context.Background(), empty, &config.CacheConfig{RestoreBatchSize: 100, RestoreDays: 7}); err != nil {
+		t.Fatal("Error restoring cache:", err)
+	}
+
+	report, err := NewReconciler(c, db, &config.ReconcileConfig{}).Check(context.Background(), true)
+	if err != nil || !report.Consistent() || report.Repaired != 0 {
+		t.Errorf("Orders older than the restore window are not drift: %+v, %v", report, err)
+	}
+	if _, ok := c.Peek("OLD"); ok {
+		t.Error("Repair must not load orders outside the restore window")
+	}
+}
+
+func TestReconcilerWaitsForWarmup(t *testing.T) {
+	db := &mockDatabase{orders: map[string]*models.Order{}}
+	_, err := NewReconciler(cache.NewCache(), db, &config.ReconcileConfig{}).Check(context.Background(), false)
+	if !errors.Is(err, ErrWarmingUp) {
+		t.Errorf("Expected ErrWarmingUp, got %v", err)
+	}
+}
+
+// blockingDatabase holds every scan until release is closed.
+type blockingDatabase struct {
+	mockDatabase
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingDatabase) IterateOrders(ctx context.Context, since time.Time, batchSize int, fn func(batch []*models.Order) error) error {
+	close(b.started)
+	<-b.release
+	return b.mockDatabase.IterateOrders(ctx, since, batchSize, fn)
+}
+
+func TestReconcilerRunsOneCheckAtATime(t *testing.T) {
+	db := &blockingDatabase{
+		mockDatabase: mockDatabase{orders: map[string]*models.Order{"A": order("A", 1)}},
+		started:      make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	r := NewReconciler(newTestCache(t, &db.mockDatabase), db, &config.ReconcileConfig{})
+
+	done := make(chan error)
+	go func() {
+		_, err := r.Check(context.Background(), false)
+		done <- err
+	}()
+	<-db.started
+
+	if report, err := r.Check(context.Background(), false); !errors.Is(err, ErrInProgress) || report != nil {
+		t.Errorf("Expected ErrInProgress while a check runs, got %+v, %v", report, err)
+	}
+	close(db.release)
+	if err := <-done; err != nil {
+		t.Errorf("The running check should finish, got %v", err)
+	}
+}
+
+func TestHashIgnoresTimeZoneAndPrecision(t *testing.T) {
+	a := order("A", 1)
+	b := order("A", 1)
+	b.DateCreated = time.Date(2024, 1, 1, 12, 0, 0, 999, time.FixedZone("MSK", 3*3600))
+	if Hash(a) != Hash(b) {
+		t.Error("Hash should compare wall clock at microsecond precision")
+	}
+
+	b.Items[0].Price = 100
+	if Hash(a) == Hash(b) {
+		t.Error("Hash should change with content")
+	}
+}
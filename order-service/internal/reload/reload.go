@@ -0,0 +1,108 @@
+package reload
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"order-service/config"
+	"order-service/internal/logging"
+)
+
+// Reloader keeps the running configuration and hands the runtime-tunable
+// part of a newly loaded one to the subsystems that subscribed to it.
+type Reloader struct {
+	load func() (*config.Config, error)
+
+	mu          sync.Mutex
+	current     *config.Config
+	subscribers []func(cfg *config.Config)
+}
+
+// New returns a Reloader starting from current. load reads the
+// configuration again from the same file, environment and flags.
+func New(current *config.Config, load func() (*config.Config, error)) *Reloader {
+	return &Reloader{current: current, load: load}
+}
+
+// Subscribe registers fn to be called with the new configuration after
+// every successful reload.
+func (r *Reloader) Subscribe(fn func(cfg *config.Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Current returns the configuration in effect.
+func (r *Reloader) Current() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload loads the configuration again. If it fails to load or validate,
+// the current one stays in effect. Otherwise the settings that can change
+// at runtime are applied; changes to any other setting are only logged,
+// since they take a restart.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		slog.Error("configuration reload rejected, keeping the current one", logging.Err(err))
+		return err
+	}
+
+	reloadable, restart := r.current.Changes(next)
+	if len(restart) > 0 {
+		slog.Warn("configuration changes ignored until restart", "settings", restart)
+	}
+	if len(reloadable) == 0 {
+		slog.Info("configuration reloaded, nothing to apply")
+		return nil
+	}
+
+	r.current = r.current.WithReloadable(next)
+	for _, fn := range r.subscribers {
+		fn(r.current)
+	}
+	slog.Info("configuration reloaded", "settings", reloadable)
+	return nil
+}
+
+// Watch reloads whenever the file at path changes, checking every
+// interval, until ctx is done. Editors often replace the file rather than
+// write it in place, so the modification time and size are compared
+// instead of watching the inode.
+func (r *Reloader) Watch(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if last != nil {
+				slog.Warn("config file unavailable", "path", path, logging.Err(err))
+			}
+			last = nil
+			continue
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+
+		slog.Info("config file changed", "path", path)
+		r.Reload()
+	}
+}
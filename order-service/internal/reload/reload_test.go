@@ -0,0 +1,103 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-service/config"
+)
+
+func TestReloadAppliesOnlyReloadableSettings(t *testing.T) {
+	current := config.Default()
+	next := config.Default()
+	next.Log.Level = "debug"
+	next.Cache.MaxEntries = 100
+	next.HTTP.Port = "9000"
+
+	reloader := New(current, func() (*config.Config, error) { return next, nil })
+	var applied *config.Config
+	reloader.Subscribe(func(cfg *config.Config) { applied = cfg })
+
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if applied == nil || applied != reloader.Current() {
+		t.Fatal("Subscribers should receive the new configuration")
+	}
+	if applied.Log.Level != "debug" || applied.Cache.MaxEntries != 100 {
+		t.Errorf("Reloadable settings should be applied, got %+v %+v", applied.Log, applied.Cache)
+	}
+	if applied.HTTP.Port != current.HTTP.Port {
+		t.Errorf("Restart-only settings must keep their value, got port %q", applied.HTTP.Port)
+	}
+	if current.Log.Level != "info" {
+		t.Error("Reload must not modify the previous configuration")
+	}
+}
+
+func TestReloadKeepsConfigOnError(t *testing.T) {
+	current := config.Default()
+	reloader := New(current, func() (*config.Config, error) { return nil, errors.New("invalid configuration") })
+	called := false
+	reloader.Subscribe(func(*config.Config) { called = true })
+
+	if err := reloader.Reload(); err == nil {
+		t.Error("Expected the load error")
+	}
+	if called || reloader.Current() != current {
+		t.Error("A rejected reload must keep the current configuration")
+	}
+}
+
+func TestReloadWithoutChanges(t *testing.T) {
+	current := config.Default()
+	next := config.Default()
+	next.Database.Host = "elsewhere"
+	reloader := New(current, func() (*config.Config, error) { return next, nil })
+	called := false
+	reloader.Subscribe(func(*config.Config) { called = true })
+
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if called || reloader.Current() != current {
+		t.Error("Subscribers should not run when nothing reloadable changed")
+	}
+}
+
+func TestWatchReloadsOnFileChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("log:\n  level: info\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var loads atomic.Int32
+	reloader := New(config.Default(), func() (*config.Config, error) {
+		loads.Add(1)
+		return config.Default(), nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, path, 5*time.Millisecond)
+
+	time.Sleep(30 * time.Millisecond)
+	if n := loads.Load(); n != 0 {
+		t.Fatalf("An unchanged file should not be reloaded, got %d loads", n)
+	}
+
+	if err := os.WriteFile(path, []byte("log:\n  level: debug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for loads.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a reload after the file changed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-service/internal/database"
+	"order-service/internal/metrics"
+	"order-service/internal/models"
+	"order-service/internal/tracing"
+)
+
+// ErrMalformed is returned for payloads that are not a JSON order.
+var ErrMalformed = errors.New("malformed order")
+
+type Cache interface {
+	Store(order *models.Order, created bool)
+}
+
+type Database interface {
+	SaveOrder(ctx context.Context, order *models.Order) (created bool, err error)
+}
+
+// Service is the order ingestion pipeline shared by the broker
+// subscriber and the HTTP API: decode, validate, save, cache.
+type Service struct {
+	cache   Cache
+	db      Database
+	metrics *metrics.Metrics
+}
+
+type Option func(*Service)
+
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Service) {
+		s.metrics = m
+	}
+}
+
+func NewService(cache Cache, db Database, opts ...Option) *Service {
+	s := &Service{cache: cache, db: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Ingest decodes and submits a JSON order. Besides database errors it
+// returns ErrMalformed or *models.ValidationError; the order is returned
+// whenever it could be decoded.
+func (s *Service) Ingest(ctx context.Context, data []byte) (*models.Order, error) {
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return &order, s.Submit(ctx, &order)
+}
+
+// Submit validates and saves order, then caches it. Saving returns
+// database.ErrDuplicateOrder and database.ErrStaleVersion for versions
+// already seen.
+func (s *Service) Submit(ctx context.Context, order *models.Order) error {
+	if err := order.Validate(); err != nil {
+		return err
+	}
+	created, err := s.save(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	_, span := tracing.Start(ctx, "cache.Store")
+	s.cache.Store(order, created)
+	span.End()
+	return nil
+}
+
+func (s *Service) save(ctx context.Context, order *models.Order) (bool, error) {
+	start := time.Now()
+	created, err := s.db.SaveOrder(ctx, order)
+
+	result := metrics.SaveOK
+	switch {
+	case errors.Is(err, database.ErrDuplicateOrder):
+		result = metrics.SaveDuplicate
+	case errors.Is(err, database.ErrStaleVersion):
+		result = metrics.SaveStale
+	case err != nil:
+		result = metrics.SaveError
+	}
+	s.metrics.ObserveSave(result, time.Since(start))
+	return created, err
+}
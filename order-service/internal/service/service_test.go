@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"order-service/config"
+	"order-service/internal/cache"
+	"order-service/internal/database"
+	"order-service/internal/events"
+	"order-service/internal/fixtures"
+	"order-service/internal/models"
+)
+
+type mockCache struct {
+	data map[string]*models.Order
+}
+
+func (m *mockCache) Store(order *models.Order, created bool) {
+	m.data[order.OrderUID] = order
+}
+
+type mockDatabase struct {
+	saveErr error
+	saves   int
+	stored  map[string]bool
+}
+
+func (m *mockDatabase) SaveOrder(ctx context.Context, order *models.Order) (bool, error) {
+	m.saves++
+	if m.saveErr != nil {
+		return false, m.saveErr
+	}
+	if m.stored == nil {
+		m.stored = make(map[string]bool)
+	}
+	created := !m.stored[order.OrderUID]
+	m.stored[order.OrderUID] = true
+	return created, nil
+}
+
+func TestServiceIngest(t *testing.T) {
+	invalid := fixtures.Order("INVALID")
+	invalid.Items = nil
+
+	tests := []struct {
+		name    string
+		payload interface{}
+		saveErr error
+		check   func(err error) bool
+		saves   int
+		cached  bool
+	}{
+		{"saved", fixtures.Order("A"), nil, func(err error) bool { return err == nil }, 1, true},
+		{"malformed", "{not json", nil, func(err error) bool { return errors.Is(err, ErrMalformed) }, 0, false},
+		{"invalid", invalid, nil, func(err error) bool {
+			var v *models.ValidationError
+			return errors.As(err, &v)
+		}, 0, false},
+		{"duplicate", fixtures.Order("A"), database.ErrDuplicateOrder, func(err error) bool {
+			return errors.Is(err, database.ErrDuplicateOrder)
+		}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &mockCache{data: make(map[string]*models.Order)}
+			db := &mockDatabase{saveErr: tt.saveErr}
+
+			data, _ := json.Marshal(tt.payload)
+			if s, ok := tt.payload.(string); ok {
+				data = []byte(s)
+			}
+
+			_, err := NewService(cache, db).Ingest(context.Background(), data)
+			if !tt.check(err) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if db.saves != tt.saves {
+				t.Errorf("Expected %d saves, got %d", tt.saves, db.saves)
+			}
+			if cached := len(cache.data) > 0; cached != tt.cached {
+				t.Errorf("Expected cached=%t, got %t", tt.cached, cached)
+			}
+		})
+	}
+}
+
+// An order evicted from the cache is still updated in the database, so
+// its next version must be announced as an update.
+func TestServiceReportsUpdateOfEvictedOrder(t *testing.T) {
+	hub := events.NewHub(&config.EventsConfig{History: 10, ClientBuffer: 10})
+	orders := cache.NewCache(
+		cache.WithObserver(hub.Publish),
+		cache.WithLimits(cache.Limits{MaxEntries: 1, Policy: cache.PolicyLRU}),
+	)
+	s := NewService(orders, &mockDatabase{})
+	client, _ := hub.Subscribe(0, events.Filter{})
+
+	updated := fixtures.Order("A")
+	updated.Version = 1
+	for _, order := range []*models.Order{fixtures.Order("A"), fixtures.Order("B"), updated} {
+		if err := s.Submit(context.Background(), order); err != nil {
+			t.Fatal("Error submitting order:", err)
+		}
+	}
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		event := <-client.C
+		got = append(got, event.Order.OrderUID+":"+event.Type)
+	}
+	want := []string{"A:created", "B:created", "A:updated"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,227 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"order-service/config"
+	"order-service/internal/broker"
+	"order-service/internal/database"
+	"order-service/internal/logging"
+	"order-service/internal/metrics"
+	"order-service/internal/models"
+	"order-service/internal/service"
+	"order-service/internal/tracing"
+)
+
+type Ingester interface {
+	Ingest(ctx context.Context, data []byte) (*models.Order, error)
+}
+
+type Database interface {
+	SaveDeadLetter(dl *models.DeadLetter) error
+}
+
+type Subscriber struct {
+	broker          broker.Broker
+	ingester        Ingester
+	db              Database
+	maxRedeliveries int
+	backoff         []time.Duration
+	metrics         *metrics.Metrics
+
+	mu       sync.Mutex
+	draining bool
+	inflight sync.WaitGroup
+
+	lastSaved atomic.Int64
+}
+
+type Option func(*Subscriber)
+
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Subscriber) {
+		s.metrics = m
+	}
+}
+
+func NewSubscriber(b broker.Broker, ingester Ingester, db Database, cfg *config.BrokerConfig, opts ...Option) *Subscriber {
+	s := &Subscriber{
+		broker:          b,
+		ingester:        ingester,
+		db:              db,
+		maxRedeliveries: cfg.MaxRedeliveries,
+		backoff:         cfg.Backoff,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Subscriber) Subscribe() error {
+	return s.broker.Subscribe(s.handleMessage)
+}
+
+// Drain stops taking messages from the broker and waits until those being
+// handled are acked or nacked, or ctx is done. Messages delivered after
+// Drain is called are left unacked for the broker to redeliver.
+func (s *Subscriber) Drain(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+
+	if err := s.broker.Unsubscribe(); err != nil {
+		slog.Warn("unsubscribing from broker failed", logging.Err(err))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for in-flight messages: %w", ctx.Err())
+	}
+}
+
+// begin registers a message as in flight unless the subscriber is
+// draining. The check and the Add happen under one lock, so Drain never
+// starts waiting before a message it let through is counted.
+func (s *Subscriber) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+// handleMessage continues the trace found in the message headers, if the
+// transport carries any, so a publisher's trace covers the save as well.
+// Every record it logs carries the subject and sequence, and the order
+// UID once the payload has been decoded.
+func (s *Subscriber) handleMessage(msg broker.Message) {
+	if !s.begin() {
+		return
+	}
+	defer s.inflight.Done()
+
+	meta := msg.Metadata()
+	ctx := logging.With(context.Background(),
+		slog.String(logging.KeySubject, meta.Subject),
+		slog.Uint64(logging.KeySequence, meta.Sequence),
+	)
+	slog.DebugContext(ctx, "message received", "redeliveries", meta.Redeliveries)
+	s.metrics.MessageReceived(meta.Redeliveries)
+
+	ctx, span := tracing.Start(tracing.Extract(ctx, meta.Headers), "subscriber.handleMessage",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", meta.Subject),
+			attribute.Int64("messaging.message.sequence", int64(meta.Sequence)),
+			attribute.Int("messaging.message.redeliveries", meta.Redeliveries),
+		),
+	)
+	order, err := s.ingester.Ingest(ctx, msg.Data())
+	defer tracing.End(span, err)
+	if order != nil {
+		ctx = logging.WithOrderUID(ctx, order.OrderUID)
+	}
+
+	var invalid *models.ValidationError
+	switch {
+	case errors.Is(err, service.ErrMalformed):
+		slog.WarnContext(ctx, "malformed message", logging.Err(err))
+		s.metrics.MessageFailed(metrics.ReasonMalformed)
+		s.deadLetter(ctx, msg, err)
+		return
+	case errors.As(err, &invalid):
+		slog.WarnContext(ctx, "order rejected", "violations", invalid.Violations)
+		s.metrics.MessageFailed(metrics.ReasonInvalid)
+		s.deadLetter(ctx, msg, err)
+		return
+	case errors.Is(err, database.ErrDuplicateOrder):
+		slog.InfoContext(ctx, "order version already stored, skipping", "version", order.Version)
+		s.ack(msg)
+		return
+	case errors.Is(err, database.ErrStaleVersion):
+		slog.WarnContext(ctx, "order rejected", logging.Err(err))
+		s.metrics.MessageFailed(metrics.ReasonStale)
+		s.deadLetter(ctx, msg, err)
+		return
+	case err != nil:
+		slog.ErrorContext(ctx, "saving order failed", "redeliveries", meta.Redeliveries, logging.Err(err))
+		s.metrics.MessageFailed(metrics.ReasonSaveError)
+		if meta.Redeliveries >= s.maxRedeliveries {
+			s.deadLetter(ctx, msg, err)
+			return
+		}
+		s.nack(msg)
+		return
+	}
+
+	slog.InfoContext(ctx, "order saved", "version", order.Version)
+	s.lastSaved.Store(time.Now().UnixNano())
+	s.ack(msg)
+}
+
+// LastMessageAt returns when an order from the broker was last saved, or
+// the zero time if none has been since start.
+func (s *Subscriber) LastMessageAt() time.Time {
+	if n := s.lastSaved.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+func (s *Subscriber) ack(msg broker.Message) {
+	msg.Ack()
+	s.metrics.MessageAcked()
+}
+
+func (s *Subscriber) nack(msg broker.Message) {
+	msg.Nack(s.retryDelay(msg.Metadata().Redeliveries))
+	s.metrics.MessageNacked()
+}
+
+func (s *Subscriber) deadLetter(ctx context.Context, msg broker.Message, cause error) {
+	meta := msg.Metadata()
+	dl := &models.DeadLetter{
+		Subject:      meta.Subject,
+		Sequence:     meta.Sequence,
+		Redeliveries: meta.Redeliveries,
+		Payload:      string(msg.Data()),
+		Error:        cause.Error(),
+	}
+	if err := s.db.SaveDeadLetter(dl); err != nil {
+		slog.ErrorContext(ctx, "saving dead letter failed", logging.Err(err))
+		s.nack(msg)
+		return
+	}
+
+	slog.InfoContext(ctx, "message moved to dead letters", "dead_letter_id", dl.ID)
+	s.metrics.MessageDeadLettered()
+	s.ack(msg)
+}
+
+func (s *Subscriber) retryDelay(redeliveries int) time.Duration {
+	if len(s.backoff) == 0 {
+		return 0
+	}
+	if redeliveries >= len(s.backoff) {
+		return s.backoff[len(s.backoff)-1]
+	}
+	return s.backoff[redeliveries]
+}
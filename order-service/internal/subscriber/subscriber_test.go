@@ -0,0 +1,387 @@
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"order-service/config"
+	"order-service/internal/broker"
+	"order-service/internal/database"
+	"order-service/internal/fixtures"
+	"order-service/internal/metrics"
+	"order-service/internal/models"
+	"order-service/internal/service"
+	"order-service/internal/tracing"
+	"order-service/internal/tracing/tracingtest"
+)
+
+type mockCache struct {
+	mu   sync.Mutex
+	data map[string]*models.Order
+}
+
+func newMockCache() *mockCache {
+	return &mockCache{data: make(map[string]*models.Order)}
+}
+
+func (m *mockCache) Store(order *models.Order, created bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[order.OrderUID] = order
+}
+
+func (m *mockCache) has(orderUID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.data[orderUID]
+	return ok
+}
+
+type mockDatabase struct {
+	mu          sync.Mutex
+	saveErr     error
+	saves       int
+	deadLetters []*models.DeadLetter
+}
+
+func (m *mockDatabase) SaveOrder(ctx context.Context, order *models.Order) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saves++
+	return m.saveErr == nil, m.saveErr
+}
+
+func (m *mockDatabase) SaveDeadLetter(dl *models.DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dl.ID = int64(len(m.deadLetters) + 1)
+	m.deadLetters = append(m.deadLetters, dl)
+	return nil
+}
+
+func (m *mockDatabase) deadLetterCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.deadLetters)
+}
+
+func newTestSubscriber(t *testing.T, cache *mockCache, db *mockDatabase) *broker.Memory {
+	t.Helper()
+	b := broker.NewMemory()
+	t.Cleanup(func() { b.Close() })
+
+	cfg := &config.BrokerConfig{MaxRedeliveries: 2, Backoff: []time.Duration{time.Millisecond}}
+	if err := NewSubscriber(b, service.NewService(cache, db), db, cfg).Subscribe(); err != nil {
+		t.Fatal("Error subscribing:", err)
+	}
+	return b
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func TestSubscriberSavesOrder(t *testing.T) {
+	cache := newMockCache()
+	b := newTestSubscriber(t, cache, &mockDatabase{})
+
+	data, _ := json.Marshal(fixtures.Order("ORDER_1"))
+	b.Publish("orders", data)
+
+	waitFor(t, "ack", func() bool { return len(b.Acked()) == 1 })
+	if !cache.has("ORDER_1") {
+		t.Error("Saved order should be cached")
+	}
+}
+
+func TestSubscriberDeadLettersPoisonMessage(t *testing.T) {
+	db := &mockDatabase{}
+	b := newTestSubscriber(t, newMockCache(), db)
+
+	b.Publish("orders", []byte("{not json"))
+
+	waitFor(t, "ack", func() bool { return len(b.Acked()) == 1 })
+	if db.deadLetterCount() != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", db.deadLetterCount())
+	}
+	if db.deadLetters[0].Redeliveries != 0 {
+		t.Errorf("Poison message should be dead-lettered on first delivery, got %d redeliveries", db.deadLetters[0].Redeliveries)
+	}
+}
+
+func TestSubscriberDeadLettersInvalidOrder(t *testing.T) {
+	db := &mockDatabase{}
+	b := newTestSubscriber(t, newMockCache(), db)
+
+	order := fixtures.Order("ORDER_2")
+	order.Items = nil
+	data, _ := json.Marshal(order)
+	b.Publish("orders", data)
+
+	waitFor(t, "ack", func() bool { return len(b.Acked()) == 1 })
+	if db.deadLetterCount() != 1 || db.saves != 0 {
+		t.Errorf("Invalid order must be dead-lettered without saving, got %d dead letters, %d saves", db.deadLetterCount(), db.saves)
+	}
+}
+
+func TestSubscriberRedeliversUntilMax(t *testing.T) {
+	cache := newMockCache()
+	db := &mockDatabase{saveErr: errors.New("database unavailable")}
+	b := newTestSubscriber(t, cache, db)
+
+	data, _ := json.Marshal(fixtures.Order("ORDER_3"))
+	b.Publish("orders", data)
+
+	waitFor(t, "ack", func() bool { return len(b.Acked()) == 1 })
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.saves != 3 {
+		t.Errorf("Expected 3 save attempts, got %d", db.saves)
+	}
+	if len(db.deadLetters) != 1 || db.deadLetters[0].Redeliveries != 2 {
+		t.Errorf("Expected dead letter after 2 redeliveries, got %+v", db.deadLetters)
+	}
+	if cache.has("ORDER_3") {
+		t.Error("Failed order must not be cached")
+	}
+}
+
+func TestSubscriberAcksDuplicateVersion(t *testing.T) {
+	db := &mockDatabase{saveErr: database.ErrDuplicateOrder}
+	b := newTestSubscriber(t, newMockCache(), db)
+
+	data, _ := json.Marshal(fixtures.Order("ORDER_4"))
+	b.Publish("orders", data)
+
+	waitFor(t, "ack", func() bool { return len(b.Acked()) == 1 })
+	if db.deadLetterCount() != 0 || db.saves != 1 {
+		t.Errorf("Duplicate must be acked once without dead letter, got %d dead letters, %d saves", db.deadLetterCount(), db.saves)
+	}
+}
+
+func TestSubscriberDeadLettersStaleVersion(t *testing.T) {
+	db := &mockDatabase{saveErr: database.ErrStaleVersion}
+	b := newTestSubscriber(t, newMockCache(), db)
+
+	data, _ := json.Marshal(fixtures.Order("ORDER_5"))
+	b.Publish("orders", data)
+
+	waitFor(t, "ack", func() bool { return len(b.Acked()) == 1 })
+	if db.deadLetterCount() != 1 || db.saves != 1 {
+		t.Errorf("Stale version must be dead-lettered without retry, got %d dead letters, %d saves", db.deadLetterCount(), db.saves)
+	}
+}
+
+func TestSubscriberMetrics(t *testing.T) {
+	db := &mockDatabase{saveErr: errors.New("database unavailable")}
+	m := metrics.New()
+	b := broker.NewMemory()
+	t.Cleanup(func() { b.Close() })
+
+	cfg := &config.BrokerConfig{MaxRedeliveries: 2, Backoff: []time.Duration{time.Millisecond}}
+	orders := service.NewService(newMockCache(), db, service.WithMetrics(m))
+	if err := NewSubscriber(b, orders, db, cfg, WithMetrics(m)).Subscribe(); err != nil {
+		t.Fatal("Error subscribing:", err)
+	}
+
+	data, _ := json.Marshal(fixtures.Order("ORDER_6"))
+	b.Publish("orders", data)
+	waitFor(t, "ack", func() bool { return len(b.Acked()) == 1 })
+
+	expected := `
+# HELP order_service_messages_acked_total Broker messages acknowledged, including duplicates and dead letters.
+# TYPE order_service_messages_acked_total counter
+order_service_messages_acked_total 1
+# HELP order_service_messages_dead_lettered_total Broker messages moved to dead letters.
+# TYPE order_service_messages_dead_lettered_total counter
+order_service_messages_dead_lettered_total 1
+# HELP order_service_messages_failed_total Broker messages that could not be ingested, by reason.
+# TYPE order_service_messages_failed_total counter
+order_service_messages_failed_total{reason="save_error"} 3
+# HELP order_service_messages_nacked_total Broker messages handed back for redelivery.
+# TYPE order_service_messages_nacked_total counter
+order_service_messages_nacked_total 2
+# HELP order_service_messages_received_total Broker messages received by the subscriber.
+# TYPE order_service_messages_received_total counter
+order_service_messages_received_total 3
+# HELP order_service_messages_redelivered_total Broker messages received again after an earlier delivery.
+# TYPE order_service_messages_redelivered_total counter
+order_service_messages_redelivered_total 2
+# HELP order_service_db_save_order_errors_total SaveOrder calls that did not store the order, by result.
+# TYPE order_service_db_save_order_errors_total counter
+order_service_db_save_order_errors_total{result="error"} 3
+`
+	err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected),
+		"order_service_messages_acked_total",
+		"order_service_messages_dead_lettered_total",
+		"order_service_messages_failed_total",
+		"order_service_messages_nacked_total",
+		"order_service_messages_received_total",
+		"order_service_messages_redelivered_total",
+		"order_service_db_save_order_errors_total",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubscriberContinuesTrace(t *testing.T) {
+	exporter := tracingtest.InMemory()
+	b := newTestSubscriber(t, newMockCache(), &mockDatabase{})
+
+	ctx, publish := tracing.Start(context.Background(), "publish")
+	publish.End()
+	data, _ := json.Marshal(fixtures.Order("ORDER_7"))
+	b.PublishWithHeaders("orders", data, tracing.Inject(ctx))
+	waitFor(t, "ack", func() bool { return len(b.Acked()) == 1 })
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	handle, ok := spans["subscriber.handleMessage"]
+	if !ok {
+		t.Fatalf("Expected a handleMessage span, got %v", spans)
+	}
+	if handle.Parent.SpanID() != publish.SpanContext().SpanID() {
+		t.Error("handleMessage should be a child of the publisher's span")
+	}
+	if spans["cache.Store"].Parent.SpanID() != handle.SpanContext.SpanID() {
+		t.Error("cache.Store should be a child of handleMessage")
+	}
+}
+
+// gatedDatabase blocks every save until release is closed.
+type gatedDatabase struct {
+	mockDatabase
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (g *gatedDatabase) SaveOrder(ctx context.Context, order *models.Order) (bool, error) {
+	g.once.Do(func() { close(g.started) })
+	<-g.release
+	return g.mockDatabase.SaveOrder(ctx, order)
+}
+
+func TestSubscriberDrainLosesNoMessages(t *testing.T) {
+	cache := newMockCache()
+	db := &gatedDatabase{started: make(chan struct{}), release: make(chan struct{})}
+	b := broker.NewMemory()
+	t.Cleanup(func() { b.Close() })
+
+	cfg := &config.BrokerConfig{MaxRedeliveries: 2, Backoff: []time.Duration{time.Millisecond}}
+	first := NewSubscriber(b, service.NewService(cache, db), db, cfg)
+	if err := first.Subscribe(); err != nil {
+		t.Fatal("Error subscribing:", err)
+	}
+
+	const total = 5
+	for i := 1; i <= total; i++ {
+		data, _ := json.Marshal(fixtures.Order(fmt.Sprintf("ORDER_%d", i)))
+		b.Publish("orders", data)
+	}
+	<-db.started
+
+	drained := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		drained <- first.Drain(ctx)
+	}()
+
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain returned while a message was still being saved: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(db.release)
+	if err := <-drained; err != nil {
+		t.Fatal("Drain failed:", err)
+	}
+	if acked := len(b.Acked()); acked != 1 || b.Pending() != total-1 {
+		t.Fatalf("Expected the in-flight message acked and the rest pending, got %d acked, %d pending", acked, b.Pending())
+	}
+
+	// A restarted subscriber picks up everything the first one left.
+	if err := NewSubscriber(b, service.NewService(cache, db), db, cfg).Subscribe(); err != nil {
+		t.Fatal("Error subscribing:", err)
+	}
+	waitFor(t, "remaining messages", func() bool { return b.Pending() == 0 })
+	for i := 1; i <= total; i++ {
+		if uid := fmt.Sprintf("ORDER_%d", i); !cache.has(uid) {
+			t.Errorf("Order %s was lost", uid)
+		}
+	}
+	if db.saves != total {
+		t.Errorf("Expected each order saved once, got %d saves", db.saves)
+	}
+}
+
+func TestSubscriberDrainTimesOut(t *testing.T) {
+	db := &gatedDatabase{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(db.release)
+	b := broker.NewMemory()
+	t.Cleanup(func() { b.Close() })
+
+	cfg := &config.BrokerConfig{MaxRedeliveries: 2}
+	s := NewSubscriber(b, service.NewService(newMockCache(), db), db, cfg)
+	if err := s.Subscribe(); err != nil {
+		t.Fatal("Error subscribing:", err)
+	}
+	data, _ := json.Marshal(fixtures.Order("ORDER_1"))
+	b.Publish("orders", data)
+	<-db.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Drain to give up at the deadline, got %v", err)
+	}
+}
+
+func TestSubscriberLastMessageAt(t *testing.T) {
+	db := &mockDatabase{}
+	b := broker.NewMemory()
+	t.Cleanup(func() { b.Close() })
+
+	s := NewSubscriber(b, service.NewService(newMockCache(), db), db, &config.BrokerConfig{MaxRedeliveries: 2})
+	if err := s.Subscribe(); err != nil {
+		t.Fatal("Error subscribing:", err)
+	}
+	if !s.LastMessageAt().IsZero() {
+		t.Error("Expected no last message before any was saved")
+	}
+
+	b.Publish("orders", []byte("not json"))
+	waitFor(t, "ack", func() bool { return len(b.Acked()) == 1 })
+	if !s.LastMessageAt().IsZero() {
+		t.Error("A dead-lettered message is not a successful one")
+	}
+
+	before := time.Now()
+	data, _ := json.Marshal(fixtures.Order("ORDER_8"))
+	b.Publish("orders", data)
+	waitFor(t, "ack", func() bool { return len(b.Acked()) == 2 })
+	if s.LastMessageAt().Before(before) {
+		t.Errorf("Expected last message time after %v, got %v", before, s.LastMessageAt())
+	}
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"order-service/config"
+	"order-service/internal/tracing/tracingtest"
+)
+
+func TestInjectExtract(t *testing.T) {
+	exporter := tracingtest.InMemory()
+
+	ctx, span := Start(context.Background(), "parent")
+	headers := Inject(ctx)
+	span.End()
+	if headers["traceparent"] == "" {
+		t.Fatalf("Expected a traceparent header, got %v", headers)
+	}
+
+	_, child := Start(Extract(context.Background(), headers), "child")
+	child.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if spans[1].Parent.SpanID() != spans[0].SpanContext.SpanID() {
+		t.Error("Extracted context should make the first span the parent")
+	}
+}
+
+func TestInjectWithoutSpan(t *testing.T) {
+	if headers := Inject(context.Background()); headers != nil {
+		t.Errorf("Expected no headers without a span, got %v", headers)
+	}
+	if ctx := Extract(context.Background(), nil); trace.SpanContextFromContext(ctx).IsValid() {
+		t.Error("Extracting no headers should leave the context untouched")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{"", ExporterNone, ExporterStdout} {
+		shutdown, err := Setup(context.Background(), &config.TracingConfig{Exporter: exporter, SampleRatio: 1})
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", exporter, err)
+			continue
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("%q: shutdown error: %v", exporter, err)
+		}
+	}
+
+	if _, err := Setup(context.Background(), &config.TracingConfig{Exporter: "zipkin"}); err == nil {
+		t.Error("Expected an error for an unknown exporter")
+	}
+}